@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/api"
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/llm"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// evalCase 评测数据集中的一条用例
+type evalCase struct {
+	// Input 用户说法
+	Input string `yaml:"input" json:"input"`
+
+	// Processor 期望匹配的处理器ID，为空表示期望不匹配任何处理器
+	Processor string `yaml:"processor" json:"processor"`
+
+	// Parameters 期望提取的参数（只比较列出的参数）
+	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+}
+
+// evalDataset YAML格式的数据集
+type evalDataset struct {
+	Cases []evalCase `yaml:"cases"`
+}
+
+// evalCaseResult 单条用例的评测结果
+type evalCaseResult struct {
+	evalCase
+
+	// Predicted 实际匹配的处理器ID
+	Predicted string `json:"predicted"`
+
+	// Confidence 实际匹配的置信度
+	Confidence float64 `json:"confidence"`
+
+	// ProcessorCorrect 处理器是否匹配正确
+	ProcessorCorrect bool `json:"processor_correct"`
+
+	// ExtractedParameters 实际提取的参数
+	ExtractedParameters map[string]interface{} `json:"extracted_parameters,omitempty"`
+
+	// ParamsTotal 期望的参数个数
+	ParamsTotal int `json:"params_total"`
+
+	// ParamsCorrect 提取正确的参数个数
+	ParamsCorrect int `json:"params_correct"`
+
+	// ParamMismatches 提取错误的参数说明
+	ParamMismatches []string `json:"param_mismatches,omitempty"`
+
+	// LatencyMs 匹配+提取耗时（毫秒）
+	LatencyMs int64 `json:"latency_ms"`
+
+	// Usage token用量
+	Usage llm.UsageStats `json:"usage"`
+
+	// Message 网关未执行该指令时的提示（缺少参数、参数无效、敏感处理器被拒绝等）
+	Message string `json:"message,omitempty"`
+
+	// Choices 匹配有歧义时的候选处理器
+	Choices []string `json:"choices,omitempty"`
+
+	// Error 调用错误
+	Error string `json:"error,omitempty"`
+}
+
+// correct 用例是否完全正确（处理器正确且期望参数全部正确）
+func (r *evalCaseResult) correct() bool {
+	return r.Error == "" && r.ProcessorCorrect && r.ParamsCorrect == r.ParamsTotal
+}
+
+// evalProcessorStats 单个处理器的精确率/召回率
+type evalProcessorStats struct {
+	TruePositive  int     `json:"tp"`
+	FalsePositive int     `json:"fp"`
+	FalseNegative int     `json:"fn"`
+	Precision     float64 `json:"precision"`
+	Recall        float64 `json:"recall"`
+}
+
+// evalSummary 评测汇总
+type evalSummary struct {
+	Cases             int                            `json:"cases"`
+	Errors            int                            `json:"errors"`
+	ProcessorAccuracy float64                        `json:"processor_accuracy"`
+	ParamAccuracy     float64                        `json:"param_accuracy"`
+	ExactAccuracy     float64                        `json:"exact_accuracy"`
+	LatencyAvgMs      int64                          `json:"latency_avg_ms"`
+	LatencyP50Ms      int64                          `json:"latency_p50_ms"`
+	LatencyP95Ms      int64                          `json:"latency_p95_ms"`
+	Usage             llm.UsageStats                 `json:"usage"`
+	Cost              float64                        `json:"cost"`
+	Processors        map[string]*evalProcessorStats `json:"processors"`
+}
+
+// evalRun 一次完整的评测结果（-out 输出，-baseline 读取）
+type evalRun struct {
+	StartedAt time.Time         `json:"started_at"`
+	Model     string            `json:"model"`
+	Dataset   string            `json:"dataset"`
+	Summary   evalSummary       `json:"summary"`
+	Results   []*evalCaseResult `json:"results"`
+}
+
+// runEval 执行 eval 子命令，返回进程退出码
+func runEval(args []string) int {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	var (
+		configPath      string
+		processorsPath  string
+		datasetPath     string
+		outPath         string
+		baselinePath    string
+		concurrency     int
+		promptPrice     float64
+		completionPrice float64
+	)
+	fs.StringVar(&configPath, "c", "configs/config.yaml", "主配置文件路径")
+	fs.StringVar(&processorsPath, "p", "configs/processors", "处理器配置目录或文件路径")
+	fs.StringVar(&datasetPath, "dataset", "", "评测数据集（.yaml/.yml 或 .jsonl）")
+	fs.StringVar(&outPath, "out", "", "评测结果输出文件（JSON），可作为下次评测的 -baseline")
+	fs.StringVar(&baselinePath, "baseline", "", "上一次评测结果文件，用于对比")
+	fs.IntVar(&concurrency, "concurrency", 4, "并发数")
+	fs.Float64Var(&promptPrice, "prompt-price", 0, "每1K输入token的价格（用于估算成本）")
+	fs.Float64Var(&completionPrice, "completion-price", 0, "每1K输出token的价格（用于估算成本）")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: home-gateway eval -dataset <文件> [选项]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if datasetPath == "" {
+		fs.Usage()
+		return 2
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	configMgr := config.NewManager(resolvePath(configPath), resolvePath(processorsPath))
+	if err := configMgr.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败: %v\n", err)
+		return 1
+	}
+	cfg := configMgr.Get()
+
+	cases, err := loadEvalDataset(datasetPath)
+	if err != nil {
+		fmt.Printf("❌ 加载数据集失败: %v\n", err)
+		return 1
+	}
+
+	var baseline *evalRun
+	if baselinePath != "" {
+		if baseline, err = loadEvalRun(baselinePath); err != nil {
+			fmt.Printf("❌ 加载对比结果失败: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("🧪 评测 %d 条用例（模型: %s，并发: %d）\n", len(cases), cfg.LLM.Model, concurrency)
+
+	run := &evalRun{
+		StartedAt: time.Now(),
+		Model:     cfg.LLM.Model,
+		Dataset:   datasetPath,
+		Results:   make([]*evalCaseResult, len(cases)),
+	}
+
+	llmClient, err := llm.NewClient(&cfg.LLM)
+	if err != nil {
+		fmt.Printf("❌ 创建LLM客户端失败: %v\n", err)
+		return 1
+	}
+	handler := api.NewHandler(configMgr, llmClient, nil)
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range cases {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			run.Results[i] = evalOne(handler, configMgr, cases[i])
+		}(i)
+	}
+	wg.Wait()
+
+	run.Summary = summarizeEval(run.Results, promptPrice, completionPrice)
+	printEvalSummary(run)
+	if baseline != nil {
+		printEvalDiff(baseline, run)
+	}
+
+	if outPath != "" {
+		data, _ := json.MarshalIndent(run, "", "  ")
+		if err := os.WriteFile(outPath, data, 0644); err != nil {
+			fmt.Printf("❌ 写入评测结果失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("\n📄 评测结果已写入 %s\n", outPath)
+	}
+
+	return 0
+}
+
+// evalOne 评测单条用例
+// 使用与网关相同的识别流程（输入检查、意图拆分、分组路由、歧义判断、敏感检查、带上下文的参数提取与校验），只是不下发到后端
+func evalOne(handler *api.Handler, configMgr *config.Manager, c evalCase) *evalCaseResult {
+	result := &evalCaseResult{evalCase: c, ParamsTotal: len(c.Parameters)}
+
+	usage := &llm.Usage{}
+	ctx := llm.WithUsage(context.Background(), usage)
+	start := time.Now()
+	defer func() {
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.Usage = usage.Stats()
+	}()
+
+	msg := model.NewUnifiedMessage(c.Input, model.ChannelHTTP, "eval", "eval", nil)
+	interpretations := handler.Interpret(ctx, msg)
+
+	// 拆分为多条指令时，预测结果为各子指令的处理器ID用"+"连接（数据集可按同样格式填写期望值）
+	var predicted []string
+	for _, it := range interpretations {
+		if it.Error != "" {
+			result.Error = it.Error
+			return result
+		}
+		predicted = append(predicted, it.ProcessorID)
+	}
+	result.Predicted = strings.Join(predicted, "+")
+	result.ProcessorCorrect = result.Predicted == c.Processor
+	if len(interpretations) != 1 {
+		return result
+	}
+
+	it := interpretations[0]
+	result.Confidence = it.Confidence
+	result.Message = it.Message
+	result.Choices = it.Choices
+	if it.ProcessorID == "" || !result.ProcessorCorrect || len(c.Parameters) == 0 {
+		return result
+	}
+
+	processor := configMgr.GetProcessor(it.ProcessorID)
+	extracted := it.Parameters
+	result.ExtractedParameters = extracted
+
+	expected := coerceExpected(processor, c.Parameters)
+	for name := range c.Parameters {
+		want := fmt.Sprint(expected[name])
+		got, ok := extracted[name]
+		if ok && fmt.Sprint(got) == want {
+			result.ParamsCorrect++
+		} else {
+			result.ParamMismatches = append(result.ParamMismatches, fmt.Sprintf("%s: 期望 %s，实际 %v", name, want, got))
+		}
+	}
+	sort.Strings(result.ParamMismatches)
+
+	return result
+}
+
+// coerceExpected 按参数定义转换期望值的类型（如数据集中写的 "26" 与提取出的 26 视为相同）
+// 转换失败时保留原值
+func coerceExpected(processor *model.Processor, params map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		out[name] = value
+		for _, p := range processor.Parameters {
+			if p.Name != name {
+				continue
+			}
+			p.Required = false
+			p.Default = nil
+			single := &model.Processor{Parameters: []model.Parameter{p}}
+			if validated, err := validator.Validate(single, map[string]interface{}{name: value}); err == nil {
+				out[name] = validated[name]
+			}
+		}
+	}
+	return out
+}
+
+// summarizeEval 计算汇总指标
+func summarizeEval(results []*evalCaseResult, promptPrice, completionPrice float64) evalSummary {
+	summary := evalSummary{
+		Cases:      len(results),
+		Processors: make(map[string]*evalProcessorStats),
+	}
+	stats := func(id string) *evalProcessorStats {
+		if id == "" {
+			id = "(none)"
+		}
+		if summary.Processors[id] == nil {
+			summary.Processors[id] = &evalProcessorStats{}
+		}
+		return summary.Processors[id]
+	}
+
+	var processorCorrect, exact, paramsTotal, paramsCorrect int
+	var latencies []int64
+	var totalLatency int64
+	for _, r := range results {
+		if r.Error != "" {
+			summary.Errors++
+		}
+		if r.ProcessorCorrect {
+			processorCorrect++
+			stats(r.Processor).TruePositive++
+		} else {
+			stats(r.Predicted).FalsePositive++
+			stats(r.Processor).FalseNegative++
+		}
+		if r.correct() {
+			exact++
+		}
+		paramsTotal += r.ParamsTotal
+		paramsCorrect += r.ParamsCorrect
+
+		latencies = append(latencies, r.LatencyMs)
+		totalLatency += r.LatencyMs
+
+		summary.Usage.Calls += r.Usage.Calls
+		summary.Usage.PromptTokens += r.Usage.PromptTokens
+		summary.Usage.CompletionTokens += r.Usage.CompletionTokens
+		summary.Usage.TotalTokens += r.Usage.TotalTokens
+	}
+
+	for _, s := range summary.Processors {
+		s.Precision = ratio(s.TruePositive, s.TruePositive+s.FalsePositive)
+		s.Recall = ratio(s.TruePositive, s.TruePositive+s.FalseNegative)
+	}
+
+	summary.ProcessorAccuracy = ratio(processorCorrect, len(results))
+	summary.ParamAccuracy = ratio(paramsCorrect, paramsTotal)
+	summary.ExactAccuracy = ratio(exact, len(results))
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		summary.LatencyAvgMs = totalLatency / int64(len(latencies))
+		summary.LatencyP50Ms = latencies[(len(latencies)-1)*50/100]
+		summary.LatencyP95Ms = latencies[(len(latencies)-1)*95/100]
+	}
+
+	summary.Cost = float64(summary.Usage.PromptTokens)/1000*promptPrice +
+		float64(summary.Usage.CompletionTokens)/1000*completionPrice
+
+	return summary
+}
+
+// ratio 计算比例，分母为0时返回1
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 1
+	}
+	return float64(n) / float64(d)
+}
+
+// printEvalSummary 输出评测报告
+func printEvalSummary(run *evalRun) {
+	s := run.Summary
+	fmt.Println()
+	fmt.Println("📊 评测结果")
+	fmt.Printf("   用例: %d（调用错误 %d）\n", s.Cases, s.Errors)
+	fmt.Printf("   处理器准确率: %.1f%%\n", s.ProcessorAccuracy*100)
+	fmt.Printf("   参数准确率:   %.1f%%\n", s.ParamAccuracy*100)
+	fmt.Printf("   完全正确率:   %.1f%%\n", s.ExactAccuracy*100)
+	fmt.Printf("   延迟: 平均 %dms，P50 %dms，P95 %dms\n", s.LatencyAvgMs, s.LatencyP50Ms, s.LatencyP95Ms)
+	fmt.Printf("   Token: %d 次调用，输入 %d，输出 %d，合计 %d\n",
+		s.Usage.Calls, s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.TotalTokens)
+	if s.Cost > 0 {
+		fmt.Printf("   估算成本: %.4f\n", s.Cost)
+	}
+
+	fmt.Println()
+	fmt.Printf("   %-32s %6s %6s %6s %10s %10s\n", "处理器", "TP", "FP", "FN", "Precision", "Recall")
+	for _, id := range sortedKeys(s.Processors) {
+		p := s.Processors[id]
+		fmt.Printf("   %-32s %6d %6d %6d %9.1f%% %9.1f%%\n", id, p.TruePositive, p.FalsePositive, p.FalseNegative, p.Precision*100, p.Recall*100)
+	}
+
+	var failed []*evalCaseResult
+	for _, r := range run.Results {
+		if !r.correct() {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Println()
+		fmt.Printf("❌ 错误用例 (%d)\n", len(failed))
+		for _, r := range failed {
+			switch {
+			case r.Error != "":
+				fmt.Printf("   - %s: 调用失败: %s\n", r.Input, r.Error)
+			case !r.ProcessorCorrect:
+				fmt.Printf("   - %s: 期望 %s，实际 %s (%.2f)\n", r.Input, orNone(r.Processor), orNone(r.Predicted), r.Confidence)
+			default:
+				fmt.Printf("   - %s: %s\n", r.Input, strings.Join(r.ParamMismatches, "; "))
+			}
+		}
+	}
+}
+
+// printEvalDiff 与上一次评测结果对比
+func printEvalDiff(baseline, current *evalRun) {
+	b, c := baseline.Summary, current.Summary
+	fmt.Println()
+	fmt.Printf("🔍 与上次评测对比（%s，模型: %s）\n", baseline.StartedAt.Format("2006-01-02 15:04"), baseline.Model)
+	fmt.Printf("   处理器准确率: %.1f%% -> %.1f%% (%+.1f)\n", b.ProcessorAccuracy*100, c.ProcessorAccuracy*100, (c.ProcessorAccuracy-b.ProcessorAccuracy)*100)
+	fmt.Printf("   参数准确率:   %.1f%% -> %.1f%% (%+.1f)\n", b.ParamAccuracy*100, c.ParamAccuracy*100, (c.ParamAccuracy-b.ParamAccuracy)*100)
+	fmt.Printf("   完全正确率:   %.1f%% -> %.1f%% (%+.1f)\n", b.ExactAccuracy*100, c.ExactAccuracy*100, (c.ExactAccuracy-b.ExactAccuracy)*100)
+	fmt.Printf("   P95延迟:      %dms -> %dms\n", b.LatencyP95Ms, c.LatencyP95Ms)
+	fmt.Printf("   Token合计:    %d -> %d\n", b.Usage.TotalTokens, c.Usage.TotalTokens)
+
+	for _, id := range sortedKeys(c.Processors) {
+		cp := c.Processors[id]
+		bp, ok := b.Processors[id]
+		if !ok {
+			continue
+		}
+		if cp.Precision != bp.Precision || cp.Recall != bp.Recall {
+			fmt.Printf("   %-32s P %.1f%% -> %.1f%%, R %.1f%% -> %.1f%%\n", id, bp.Precision*100, cp.Precision*100, bp.Recall*100, cp.Recall*100)
+		}
+	}
+
+	previous := make(map[string]*evalCaseResult, len(baseline.Results))
+	for _, r := range baseline.Results {
+		previous[r.Input] = r
+	}
+	var regressions, fixes []string
+	for _, r := range current.Results {
+		prev, ok := previous[r.Input]
+		if !ok {
+			continue
+		}
+		switch {
+		case prev.correct() && !r.correct():
+			regressions = append(regressions, r.Input)
+		case !prev.correct() && r.correct():
+			fixes = append(fixes, r.Input)
+		}
+	}
+	if len(regressions) > 0 {
+		fmt.Printf("   ⚠️  退化 (%d): %s\n", len(regressions), strings.Join(regressions, " | "))
+	}
+	if len(fixes) > 0 {
+		fmt.Printf("   ✅ 修复 (%d): %s\n", len(fixes), strings.Join(fixes, " | "))
+	}
+}
+
+// loadEvalDataset 加载评测数据集，支持YAML（cases列表或顶层列表）和JSONL
+func loadEvalDataset(path string) ([]evalCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []evalCase
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl":
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			var c evalCase
+			if err := json.Unmarshal([]byte(text), &c); err != nil {
+				return nil, fmt.Errorf("第%d行解析失败: %w", line, err)
+			}
+			cases = append(cases, c)
+		}
+	default:
+		var dataset evalDataset
+		if err := yaml.Unmarshal(data, &dataset); err != nil || len(dataset.Cases) == 0 {
+			if err := yaml.Unmarshal(data, &cases); err != nil {
+				return nil, err
+			}
+		} else {
+			cases = dataset.Cases
+		}
+	}
+
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("数据集为空")
+	}
+	return cases, nil
+}
+
+// loadEvalRun 加载上一次的评测结果
+func loadEvalRun(path string) (*evalRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var run evalRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// sortedKeys 返回排序后的处理器ID
+func sortedKeys(m map[string]*evalProcessorStats) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orNone 空处理器ID显示为(none)
+func orNone(id string) string {
+	if id == "" {
+		return "(none)"
+	}
+	return id
+}
@@ -10,8 +10,11 @@ import (
 
 	"github.com/yoyo3287258/home-gateway/internal/api"
 	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/dispatch"
 	"github.com/yoyo3287258/home-gateway/internal/kafka"
 	"github.com/yoyo3287258/home-gateway/internal/llm"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/outbox"
 )
 
 // 版本信息（在编译时通过 -ldflags 注入）
@@ -22,12 +25,19 @@ var (
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		os.Exit(runEval(os.Args[2:]))
+	}
+
 	// 命令行参数
 	var (
 		configPath     string
 		processorsPath string
 		showVersion    bool
 		selfUpdate     bool
+		cassetteMode   string
+		cassettePath   string
 	)
 
 	flag.StringVar(&configPath, "config", "configs/config.yaml", "主配置文件路径")
@@ -38,6 +48,8 @@ func main() {
 	flag.BoolVar(&showVersion, "v", false, "显示版本信息 (简写)")
 	flag.BoolVar(&selfUpdate, "update", false, "检查并更新到最新版本")
 	flag.BoolVar(&selfUpdate, "U", false, "检查并更新到最新版本 (简写)")
+	flag.StringVar(&cassetteMode, "llm-cassette-mode", "", "LLM录制/回放模式: record, replay（覆盖 llm.cassette.mode）")
+	flag.StringVar(&cassettePath, "llm-cassette", "", "LLM录制文件路径（覆盖 llm.cassette.path）")
 	flag.Parse()
 
 	// 显示版本
@@ -58,16 +70,8 @@ func main() {
 	}
 
 	// 确保配置文件路径是绝对路径
-	if !filepath.IsAbs(configPath) {
-		execDir, _ := os.Executable()
-		execDir = filepath.Dir(execDir)
-		configPath = filepath.Join(execDir, configPath)
-	}
-	if !filepath.IsAbs(processorsPath) {
-		execDir, _ := os.Executable()
-		execDir = filepath.Dir(execDir)
-		processorsPath = filepath.Join(execDir, processorsPath)
-	}
+	configPath = resolvePath(configPath)
+	processorsPath = resolvePath(processorsPath)
 
 	// 打印启动信息
 	fmt.Println("🏠 Home Gateway 启动中...")
@@ -84,6 +88,14 @@ func main() {
 
 	cfg := configMgr.Get()
 
+	// 命令行参数覆盖录制/回放配置
+	if cassetteMode != "" {
+		cfg.LLM.Cassette.Mode = cassetteMode
+	}
+	if cassettePath != "" {
+		cfg.LLM.Cassette.Path = cassettePath
+	}
+
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
 		fmt.Printf("❌ 配置验证失败: %v\n", err)
@@ -91,21 +103,65 @@ func main() {
 	}
 
 	// 创建LLM客户端
-	llmClient := llm.NewClient(&cfg.LLM)
-	fmt.Printf("   LLM: %s (%s)\n", cfg.LLM.BaseURL, cfg.LLM.Model)
+	llmClient, err := llm.NewClient(&cfg.LLM)
+	if err != nil {
+		fmt.Printf("❌ 创建LLM客户端失败: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.LLM.Provider == llm.ProviderMock {
+		fmt.Println("   LLM: 内置模拟 (mock，按处理器关键词和规则应答)")
+	} else {
+		fmt.Printf("   LLM: %s (%s)\n", cfg.LLM.BaseURL, cfg.LLM.Model)
+	}
+	if cfg.LLM.Cassette.Mode != "" {
+		fmt.Printf("   LLM录制/回放: %s (%s)\n", cfg.LLM.Cassette.Mode, cfg.LLM.Cassette.Path)
+	}
 
-	// 创建Kafka客户端（可选）
-	var kafkaClient *kafka.Client
+	// 创建分发后端（可选）
+	router := dispatch.NewRouter(cfg.Dispatch.Backend)
 	if len(cfg.Kafka.Brokers) > 0 && cfg.Kafka.Brokers[0] != "" {
-		var err error
-		kafkaClient, err = kafka.NewClient(&cfg.Kafka)
+		var box *outbox.Outbox
+		if cfg.Kafka.Outbox.Dir != "" {
+			var err error
+			box, err = outbox.Open(resolvePath(cfg.Kafka.Outbox.Dir), cfg.Kafka.Outbox.SegmentSize, cfg.Kafka.Outbox.MaxAge)
+			if err != nil {
+				fmt.Printf("⚠️  打开本地请求队列失败（不启用队列）: %v\n", err)
+			} else {
+				fmt.Printf("   本地请求队列: %s\n", cfg.Kafka.Outbox.Dir)
+			}
+		}
+
+		kafkaClient, err := kafka.NewClient(&cfg.Kafka)
 		if err != nil {
-			fmt.Printf("⚠️  Kafka连接失败（将以无Kafka模式运行）: %v\n", err)
+			fmt.Printf("⚠️  Kafka连接失败（后台每%v重连）: %v\n", cfg.Kafka.Outbox.RetryInterval, err)
 		} else {
 			fmt.Printf("   Kafka: %v\n", cfg.Kafka.Brokers)
+			fmt.Printf("   Kafka响应topic: %s (实例: %s)\n", cfg.Kafka.ReplyTopicName(), cfg.Kafka.InstanceID)
+		}
+		router.Add(dispatch.NewKafka(&cfg.Kafka, kafkaClient, box))
+	} else {
+		fmt.Println("   Kafka: 未配置")
+	}
+	if cfg.Dispatch.MQTT.Broker != "" {
+		mqttClient, err := dispatch.NewMQTT(&cfg.Dispatch.MQTT)
+		if err != nil {
+			fmt.Printf("⚠️  MQTT连接失败（使用MQTT的处理器将返回错误）: %v\n", err)
+			router.MarkUnavailable(model.BackendMQTT, err)
+		} else {
+			router.Add(mqttClient)
+			fmt.Printf("   MQTT: %s (响应topic: %s)\n", cfg.Dispatch.MQTT.Broker, cfg.Dispatch.MQTT.ReplyTopic)
 		}
+	}
+	// HTTP后端不需要连接，始终可用；进程内后端只在注册了处理函数时启用
+	router.Add(dispatch.NewHTTP(&cfg.Dispatch.HTTP))
+	if dispatch.HasLocal() {
+		router.Add(dispatch.NewLocal(cfg.Kafka.ResponseTimeout))
+	}
+
+	if router.Has(cfg.Dispatch.Backend) {
+		fmt.Printf("   分发: 默认后端 %s\n", router.DefaultBackend())
 	} else {
-		fmt.Println("   Kafka: 未配置（以测试模式运行）")
+		fmt.Printf("   分发: 默认后端 %s 未连接（未配置时使用该后端的处理器以演示模式运行）\n", router.DefaultBackend())
 	}
 
 	// 启动配置文件监听
@@ -113,10 +169,25 @@ func main() {
 		fmt.Printf("⚠️  配置文件监听启动失败: %v\n", err)
 	}
 
+	// 订阅文件与本地队列一样，相对路径基于可执行文件所在目录
+	cfg.Events.StoreFile = resolvePath(cfg.Events.StoreFile)
+
 	// 创建处理器和服务器
-	handler := api.NewHandler(configMgr, llmClient, kafkaClient)
+	handler := api.NewHandler(configMgr, llmClient, router)
 	server := api.NewServer(handler, cfg)
 
+	// 设备事件推送（可选）
+	var eventConsumer *kafka.EventConsumer
+	if cfg.Events.Enabled && len(cfg.Kafka.Brokers) > 0 && cfg.Kafka.Brokers[0] != "" {
+		var err error
+		eventConsumer, err = kafka.NewEventConsumer(&cfg.Kafka, &cfg.Events, handler.HandleEvent)
+		if err != nil {
+			fmt.Printf("⚠️  事件消费者启动失败: %v\n", err)
+		} else {
+			fmt.Printf("   设备事件: %s\n", cfg.Events.Topic)
+		}
+	}
+
 	// 处理器数量
 	processors := configMgr.GetProcessors()
 	enabledCount := 0
@@ -147,11 +218,14 @@ func main() {
 		if err := server.Stop(); err != nil {
 			fmt.Printf("关闭服务器失败: %v\n", err)
 		}
-		if kafkaClient != nil {
-			if err := kafkaClient.Close(); err != nil {
-				fmt.Printf("关闭Kafka失败: %v\n", err)
+		if eventConsumer != nil {
+			if err := eventConsumer.Close(); err != nil {
+				fmt.Printf("关闭事件消费者失败: %v\n", err)
 			}
 		}
+		if err := router.Close(); err != nil {
+			fmt.Printf("%v\n", err)
+		}
 		os.Exit(0)
 	}()
 
@@ -162,6 +236,16 @@ func main() {
 	}
 }
 
+// resolvePath 将相对路径转换为相对于可执行文件所在目录的绝对路径
+func resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	execDir, _ := os.Executable()
+	execDir = filepath.Dir(execDir)
+	return filepath.Join(execDir, path)
+}
+
 // doSelfUpdate 执行自更新
 func doSelfUpdate() error {
 	fmt.Println("🔄 检查更新...")
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yoyo3287258/home-gateway/internal/job"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// responseTimeoutKey 上下文中等待后端响应的超时时间
+type responseTimeoutKey struct{}
+
+// withResponseTimeout 为上下文设置等待后端响应的超时时间
+func withResponseTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, responseTimeoutKey{}, timeout)
+}
+
+// responseTimeout 获取上下文中的超时时间，未设置时返回0（使用 kafka.response_timeout）
+func responseTimeout(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(responseTimeoutKey{}).(time.Duration)
+	return timeout
+}
+
+// isAsyncRequest 判断请求是否要求异步执行
+// 请求体中 "async": true 或请求头 Prefer: respond-async
+func isAsyncRequest(c *gin.Context, body []byte) bool {
+	for _, pref := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return true
+		}
+	}
+
+	var req struct {
+		Async bool `json:"async"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	return req.Async
+}
+
+// processAsync 受理异步指令，在后台完成处理，返回202响应
+func (h *Handler) processAsync(c *gin.Context, msg *model.UnifiedMessage) (int, gin.H) {
+	traceID := requestTraceID(c)
+	timeout := h.configMgr.Get().Async.Timeout
+	h.jobs.Create(traceID)
+
+	go func() {
+		// 请求返回后gin的上下文会被取消，后台处理使用独立的上下文
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		ctx = withResponseTimeout(ctx, timeout)
+
+		h.jobs.Start(traceID)
+		status, body := h.handleMessage(ctx, traceID, msg)
+		state := jobState(status, body)
+		h.jobs.Finish(traceID, state, body)
+		fmt.Printf("[%s] 异步指令处理结束: %s\n", traceID, state)
+	}()
+
+	return http.StatusAccepted, gin.H{
+		"trace_id":   traceID,
+		"state":      job.StatePending,
+		"status_url": "/api/v1/requests/" + traceID,
+	}
+}
+
+// jobState 根据处理结果确定异步请求的最终状态
+func jobState(status int, body gin.H) job.State {
+	switch {
+	case status == http.StatusGatewayTimeout:
+		return job.StateExpired
+	case status >= http.StatusBadRequest:
+		return job.StateFailed
+	}
+	if queued, _ := body["queued"].(bool); queued {
+		return job.StateQueued
+	}
+	if success, ok := body["success"].(bool); ok && !success {
+		return job.StateFailed
+	}
+	return job.StateSucceeded
+}
+
+// GetRequest 查询异步指令的状态和结果
+func (h *Handler) GetRequest(c *gin.Context) {
+	j, ok := h.jobs.Get(c.Param("trace_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "请求不存在或已过期清理"})
+		return
+	}
+	c.JSON(http.StatusOK, j)
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yoyo3287258/home-gateway/internal/dispatch"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/normalize"
+	"github.com/yoyo3287258/home-gateway/internal/reply"
+	"github.com/yoyo3287258/home-gateway/internal/validator"
+)
+
+// commandResult 单条指令的处理结果
+type commandResult struct {
+	// Index 子指令序号（从1开始，单条指令时为0）
+	Index int `json:"index,omitempty"`
+
+	// Content 指令内容
+	Content string `json:"content"`
+
+	// TraceID 指令追踪ID
+	TraceID string `json:"trace_id"`
+
+	// ProcessorID 匹配到的处理器ID
+	ProcessorID string `json:"processor_id,omitempty"`
+
+	// Processor 匹配到的处理器名称
+	Processor string `json:"processor,omitempty"`
+
+	// Parameters 提取的参数
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Success 是否执行成功
+	Success bool `json:"success"`
+
+	// Queued 后端暂时不可用，指令已写入本地队列，尚未执行
+	Queued bool `json:"queued,omitempty"`
+
+	// Message 面向用户的提示信息
+	Message string `json:"message,omitempty"`
+
+	// Error 网关内部错误（LLM异常、后端超时等）
+	Error string `json:"error,omitempty"`
+
+	// MissingParams 缺少的必填参数
+	MissingParams []string `json:"missing_params,omitempty"`
+
+	// Choices 匹配有歧义时的候选处理器
+	Choices []matchChoice `json:"choices,omitempty"`
+
+	// Format 回复格式（markdown 或 plain），纯文本时省略
+	Format reply.Format `json:"format,omitempty"`
+
+	// Data 后端返回的原始结果
+	Data interface{} `json:"data,omitempty"`
+
+	// status 单条指令时返回的HTTP状态码
+	status int
+
+	// confidence 所选处理器的匹配置信度
+	confidence float64
+}
+
+// executeCommand 对单条指令执行 意图识别 -> 参数提取 -> 下发后端 的完整流程
+// parentTraceID 非空时表示该指令是拆分出来的子指令
+func (h *Handler) executeCommand(ctx context.Context, traceID, parentTraceID string, msg *model.UnifiedMessage, content string) *commandResult {
+	result := &commandResult{
+		Content: content,
+		TraceID: traceID,
+		status:  http.StatusOK,
+	}
+
+	if processor := h.resolveCommand(ctx, result, msg, parentTraceID != ""); processor != nil {
+		h.dispatchCommand(ctx, result, processor, msg, parentTraceID)
+	}
+	return result
+}
+
+// resolveCommand 对单条指令执行 意图识别 -> 歧义判断 -> 敏感检查 -> 参数提取 -> 参数校验，不下发到后端
+// 返回参数已齐全、可以下发的处理器；返回nil时 result 中已记录原因（无匹配、需要选择、缺少参数等）
+// split 表示该指令是拆分出来的子指令
+func (h *Handler) resolveCommand(ctx context.Context, result *commandResult, msg *model.UnifiedMessage, split bool) *model.Processor {
+	traceID := result.TraceID
+	content := result.Content
+
+	// 1. LLM 意图识别 (匹配处理器)
+	matchResult, err := h.matchProcessors(ctx, traceID, content)
+	if err != nil {
+		fmt.Printf("[%s] LLM匹配失败: %v\n", traceID, err)
+		result.status = http.StatusInternalServerError
+		result.Error = "意图识别服务异常"
+		return nil
+	}
+
+	processor, choices := h.selectProcessor(traceID, content, matchResult.Matches)
+	if len(choices) > 0 {
+		result.Choices = choices
+		result.Message = fmt.Sprintf("指令有歧义，可能对应：%s", strings.Join(choiceNames(choices), "、"))
+		return nil
+	}
+	if processor == nil {
+		result.Message = "抱歉，我没有理解您的指令，或者没有找到对应的功能。"
+		return nil
+	}
+	fmt.Printf("[%s] 匹配处理器: %s\n", traceID, processor.ID)
+	result.confidence = matchConfidence(matchResult.Matches, processor.ID)
+
+	if !h.checkSensitive(result, processor, result.confidence, split) {
+		return nil
+	}
+
+	if !h.prepareParameters(ctx, result, processor, msg) {
+		return nil
+	}
+	return processor
+}
+
+// runWithProcessor 在已确定处理器的情况下执行 参数提取 -> 参数校验 -> 下发后端
+func (h *Handler) runWithProcessor(ctx context.Context, result *commandResult, processor *model.Processor, msg *model.UnifiedMessage, parentTraceID string) {
+	if h.prepareParameters(ctx, result, processor, msg) {
+		h.dispatchCommand(ctx, result, processor, msg, parentTraceID)
+	}
+}
+
+// prepareParameters 提取并校验参数，参数齐全且有效时返回true
+func (h *Handler) prepareParameters(ctx context.Context, result *commandResult, processor *model.Processor, msg *model.UnifiedMessage) bool {
+	traceID := result.TraceID
+	content := result.Content
+	result.ProcessorID = processor.ID
+	result.Processor = processor.Name
+
+	// 1. LLM 参数提取
+	paramResult, err := h.llmClient.ExtractParameters(ctx, normalize.Text(content), *processor, h.promptEnv(msg, processor))
+	if err != nil {
+		fmt.Printf("[%s] 参数提取失败: %v\n", traceID, err)
+		result.status = http.StatusInternalServerError
+		result.Error = "参数解析服务异常"
+		return false
+	}
+
+	result.Parameters = paramResult.Parameters
+	if !paramResult.Success {
+		result.Message = fmt.Sprintf("指令不完整: %s", paramResult.Message)
+		result.MissingParams = paramResult.MissingRequired
+		return false
+	}
+
+	fmt.Printf("[%s] 提取参数: %v\n", traceID, paramResult.Parameters)
+
+	// 2. 参数校验与类型转换
+	params, err := h.validateParameters(ctx, traceID, content, processor, paramResult.Parameters)
+	if err != nil {
+		result.Message = fmt.Sprintf("参数无效: %v", err)
+		return false
+	}
+	result.Parameters = params
+	return true
+}
+
+// validateParameters 按处理器定义校验并转换参数
+// 校验失败且开启了 llm.validation_reprompt 时，把错误反馈给LLM重新提取一次
+func (h *Handler) validateParameters(ctx context.Context, traceID, content string, processor *model.Processor, params map[string]interface{}) (map[string]interface{}, error) {
+	// 中文数字、单位和相对时间的规范化不依赖LLM的输出是否一致
+	params = normalize.Parameters(processor, params, content, h.now())
+
+	validated, err := validator.Validate(processor, params)
+	if err == nil {
+		return validated, nil
+	}
+
+	fmt.Printf("[%s] 参数校验失败: %v\n", traceID, err)
+	if !h.configMgr.Get().LLM.ValidationReprompt {
+		return nil, err
+	}
+
+	corrected, rerr := h.llmClient.CorrectParameters(ctx, content, *processor, params, err)
+	if rerr != nil {
+		fmt.Printf("[%s] %v\n", traceID, rerr)
+		return nil, err
+	}
+	if !corrected.Success {
+		if corrected.Message != "" {
+			return nil, fmt.Errorf("%v（%s）", err, corrected.Message)
+		}
+		return nil, err
+	}
+
+	fmt.Printf("[%s] LLM修正参数: %v\n", traceID, corrected.Parameters)
+	return validator.Validate(processor, normalize.Parameters(processor, corrected.Parameters, content, h.now()))
+}
+
+// dispatchCommand 将参数已齐全的指令发送到后端并整理结果
+func (h *Handler) dispatchCommand(ctx context.Context, result *commandResult, processor *model.Processor, msg *model.UnifiedMessage, parentTraceID string) {
+	traceID := result.TraceID
+	result.ProcessorID = processor.ID
+	result.Processor = processor.Name
+
+	// 未配置分发后端时以演示模式运行
+	if h.dispatcher == nil {
+		h.demoDispatch(result, processor)
+		return
+	}
+
+	kafkaReq := &model.KafkaRequest{
+		TraceID:       traceID,
+		ParentTraceID: parentTraceID,
+		ProcessorID:   processor.ID,
+		Parameters:    result.Parameters,
+		RawMessage:    *msg,
+		CreatedAt:     time.Now(),
+	}
+	if parentTraceID != "" {
+		// 子指令只携带拆分后的内容，完整原文可通过ParentTraceID关联
+		kafkaReq.RawMessage.Content = result.Content
+	}
+
+	resp, err := h.dispatcher.SendAndWait(ctx, kafkaReq, processor.Dispatch, responseTimeout(ctx))
+	if errors.Is(err, dispatch.ErrNotConfigured) {
+		// 处理器使用的后端没有配置
+		h.demoDispatch(result, processor)
+		return
+	}
+	if errors.Is(err, dispatch.ErrUnavailable) {
+		fmt.Printf("[%s] 分发后端不可用: %v\n", traceID, err)
+		result.status = http.StatusServiceUnavailable
+		result.Error = "后端服务不可用"
+		return
+	}
+	if errors.Is(err, dispatch.ErrQueued) {
+		// 后端暂时不可用，指令已排队：已受理但尚未执行，不算成功
+		fmt.Printf("[%s] 后端不可用，指令已写入本地队列\n", traceID)
+		result.status = http.StatusAccepted
+		result.Queued = true
+		result.Message = fmt.Sprintf("后端暂时不可用，[%s] 指令已排队，恢复后会自动执行", processor.Name)
+		return
+	}
+	if err != nil {
+		fmt.Printf("[%s] 后端处理超时或失败: %v\n", traceID, err)
+		switch {
+		case errors.Is(err, dispatch.ErrMisconfigured):
+			result.status = http.StatusInternalServerError
+			result.Error = "处理器的分发配置有误"
+		case dispatch.IsTimeout(err) && processor.Dispatch.WantsResponse():
+			result.status = http.StatusGatewayTimeout
+			result.Error = "后端服务响应超时"
+		default:
+			// 未送达（kafka.ErrNotDelivered、dispatch.ErrNotDelivered）及其他发送错误
+			result.status = http.StatusBadGateway
+			result.Error = "发送指令到后端失败"
+		}
+		return
+	}
+
+	if resp == nil {
+		// 处理器不返回响应（fire-and-forget），发送成功即视为成功
+		result.Success = true
+		result.Message = fmt.Sprintf("已向 [%s] 发送指令", processor.Name)
+		return
+	}
+
+	// 整理结果
+	if !resp.Success {
+		result.Message = fmt.Sprintf("执行失败: %s", resp.Error)
+		return
+	}
+
+	// 结构化结果按处理器的回复模板或LLM转换为自然语言
+	msgResult, format := h.renderReply(ctx, traceID, processor, msg, result.Parameters, resp.Result)
+
+	// 记录后端确认的设备状态，供后续"再亮一点"之类的相对指令参考；
+	// 演示模式、不等待响应的指令没有后端确认，不记录
+	h.states.Record(processor, result.Parameters, resp.Result)
+
+	result.Success = true
+	result.Message = msgResult
+	if format == reply.FormatMarkdown {
+		result.Format = format
+	}
+	result.Data = resp.Result
+}
+
+// demoDispatch 演示模式：不发送到后端，直接返回模拟成功
+func (h *Handler) demoDispatch(result *commandResult, processor *model.Processor) {
+	result.Success = true
+	result.Message = fmt.Sprintf("已识别指令：使用 [%s] 执行操作，参数：%v (演示模式，未发送到后端)",
+		processor.Name, result.Parameters)
+}
+
+// commandResultBody 整理单条指令的响应状态码和响应体
+func commandResultBody(result *commandResult) (int, gin.H) {
+	if result.Error != "" {
+		return result.status, gin.H{
+			"error":    result.Error,
+			"trace_id": result.TraceID,
+		}
+	}
+
+	body := gin.H{
+		"message":  result.Message,
+		"success":  result.Success,
+		"trace_id": result.TraceID,
+	}
+	if result.Queued {
+		body["queued"] = true
+	}
+	if result.Processor != "" {
+		body["processor"] = result.Processor
+	}
+	if result.Parameters != nil {
+		body["parameters"] = result.Parameters
+	}
+	if len(result.MissingParams) > 0 {
+		body["missing_params"] = result.MissingParams
+	}
+	if len(result.Choices) > 0 {
+		body["choices"] = result.Choices
+	}
+	if result.Data != nil {
+		body["data"] = result.Data
+	}
+	if result.Format != "" {
+		body["format"] = result.Format
+	}
+
+	return result.status, body
+}
+
+// multiCommandResultBody 整理多条子指令的汇总响应
+// 有子指令的回复已按 MarkdownV2 渲染时，汇总也使用 MarkdownV2，其余文本转义后拼接
+func multiCommandResultBody(traceID string, results []*commandResult) (int, gin.H) {
+	markdown := false
+	for _, r := range results {
+		if r.Format == reply.FormatMarkdown {
+			markdown = true
+		}
+	}
+	text := func(s string, format reply.Format) string {
+		if markdown && format != reply.FormatMarkdown {
+			return reply.EscapeMarkdown(s)
+		}
+		return s
+	}
+
+	succeeded, queued := 0, 0
+	lines := make([]string, 0, len(results))
+	for _, r := range results {
+		mark := "❌"
+		detail, format := r.Message, r.Format
+		switch {
+		case r.Success:
+			succeeded++
+			mark = "✅"
+		case r.Queued:
+			queued++
+			mark = "⏳"
+		case r.Error != "":
+			detail, format = r.Error, ""
+		}
+		lines = append(lines, text(fmt.Sprintf("%d. %s %s：", r.Index, mark, r.Content), "")+text(detail, format))
+	}
+
+	failed := len(results) - succeeded - queued
+	header := fmt.Sprintf("共%d条指令，成功%d条，失败%d条", len(results), succeeded, failed)
+	if queued > 0 {
+		header += fmt.Sprintf("，排队%d条", queued)
+	}
+	summary := text(header, "") + "\n" + strings.Join(lines, "\n")
+
+	body := gin.H{
+		"message":  summary,
+		"success":  succeeded == len(results),
+		"results":  results,
+		"trace_id": traceID,
+	}
+	if queued > 0 && failed == 0 {
+		// 没有失败的指令，但部分指令还在排队
+		body["queued"] = true
+	}
+	if markdown {
+		body["format"] = reply.FormatMarkdown
+	}
+	return http.StatusOK, body
+}
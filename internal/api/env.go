@@ -0,0 +1,43 @@
+package api
+
+import (
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/promptctx"
+)
+
+// promptEnv 按 prompt_context 配置收集参数提取时提供给LLM的上下文
+func (h *Handler) promptEnv(msg *model.UnifiedMessage, processor *model.Processor) []string {
+	cfg := h.configMgr.Get().PromptContext
+
+	var providers []promptctx.Provider
+	for _, name := range cfg.Providers {
+		switch name {
+		case promptctx.ProviderTime:
+			providers = append(providers, &promptctx.TimeProvider{})
+		case promptctx.ProviderLocale:
+			providers = append(providers, &promptctx.LocaleProvider{Default: cfg.Locale})
+		case promptctx.ProviderDeviceState:
+			providers = append(providers, h.states)
+		}
+	}
+
+	// 录制/回放时不输出当前时间、状态更新了多久等随时间变化的内容，否则回放时无法匹配录制的请求
+	return promptctx.Collect(providers, promptctx.Request{
+		Processor: processor,
+		Message:   msg,
+		Now:       h.now(),
+		Stable:    h.llmClient.UsesCassette(),
+	})
+}
+
+// now 按 prompt_context.timezone 配置的时区返回当前时间
+func (h *Handler) now() time.Time {
+	// 配置校验时已检查过时区
+	loc, err := time.LoadLocation(h.configMgr.Get().PromptContext.Timezone)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Now().In(loc)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/yoyo3287258/home-gateway/internal/guard"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// checkInput 对用户输入做安全检查，未通过时返回拒绝结果
+func (h *Handler) checkInput(traceID, content string) *commandResult {
+	err := guard.Check(h.configMgr.Get().Guard, content)
+	if err == nil {
+		return nil
+	}
+
+	fmt.Printf("[%s] 输入被拦截: %v\n", traceID, err)
+	return &commandResult{
+		Content: content,
+		TraceID: traceID,
+		Message: fmt.Sprintf("抱歉，这条指令无法处理：%v", err),
+		status:  http.StatusBadRequest,
+	}
+}
+
+// checkSensitive 匹配到敏感处理器时做更严格的检查，未通过时改写结果并返回false
+func (h *Handler) checkSensitive(result *commandResult, processor *model.Processor, confidence float64, split bool) bool {
+	if !processor.Sensitive {
+		return true
+	}
+
+	err := guard.CheckSensitive(h.configMgr.Get().Guard, result.Content, processor, confidence, split)
+	if err == nil {
+		return true
+	}
+
+	fmt.Printf("[%s] 敏感处理器 %s 被拒绝: %v\n", result.TraceID, processor.ID, err)
+	result.ProcessorID = processor.ID
+	result.Processor = processor.Name
+	result.Message = fmt.Sprintf("为了安全，%s需要更明确的指令：%v", processor.Name, err)
+	result.status = http.StatusForbidden
+	return false
+}
+
+// matchConfidence 匹配结果中指定处理器的置信度
+func matchConfidence(matches []model.ProcessorMatch, processorID string) float64 {
+	confidence := 0.0
+	for _, m := range matches {
+		if m.ProcessorID == processorID && m.Confidence > confidence {
+			confidence = m.Confidence
+		}
+	}
+	return confidence
+}
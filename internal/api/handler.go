@@ -1,38 +1,69 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/yoyo3287258/home-gateway/internal/channel"
 	"github.com/yoyo3287258/home-gateway/internal/config"
-	"github.com/yoyo3287258/home-gateway/internal/kafka"
+	"github.com/yoyo3287258/home-gateway/internal/dispatch"
+	"github.com/yoyo3287258/home-gateway/internal/events"
+	"github.com/yoyo3287258/home-gateway/internal/idempotency"
+	"github.com/yoyo3287258/home-gateway/internal/job"
 	"github.com/yoyo3287258/home-gateway/internal/llm"
 	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/promptctx"
+	"github.com/yoyo3287258/home-gateway/internal/schema"
+	"github.com/yoyo3287258/home-gateway/internal/session"
 )
 
 // Handler API处理器
 type Handler struct {
 	configMgr   *config.Manager
 	llmClient   *llm.Client
-	kafkaClient *kafka.Client
+	dispatcher  dispatch.Dispatcher
 	parsers     map[string]channel.Parser
+	sessions    *session.Manager
+	states      *promptctx.StateStore
+	jobs        *job.Store
+
+	subscriptions *events.Store
+	notifier      *events.Notifier
+	idempotency   *idempotency.Cache
+
+	// bot 按当前 bot_token 复用的Telegram客户端（配置重载后token变化时重建）
+	botMu    sync.Mutex
+	bot      *channel.TelegramBot
+	botToken string
 }
 
 // NewHandler 创建API处理器
-func NewHandler(configMgr *config.Manager, llmClient *llm.Client, kafkaClient *kafka.Client) *Handler {
+// dispatcher 为nil时以演示模式运行，指令不发送到后端
+func NewHandler(configMgr *config.Manager, llmClient *llm.Client, dispatcher dispatch.Dispatcher) *Handler {
 	h := &Handler{
 		configMgr:   configMgr,
 		llmClient:   llmClient,
-		kafkaClient: kafkaClient,
+		dispatcher:  dispatcher,
 		parsers:     make(map[string]channel.Parser),
+		sessions:    session.NewManager(configMgr.Get().Session.Timeout),
+		states:      promptctx.NewStateStore(configMgr.Get().PromptContext.StateTTL),
+		jobs:        job.NewStore(configMgr.Get().Async.Timeout, configMgr.Get().Async.Retention),
+		idempotency: idempotency.NewCache(configMgr.Get().Idempotency.TTL),
 	}
 	
+	subscriptions, err := events.NewStore(configMgr.Get().Events.StoreFile)
+	if err != nil {
+		fmt.Printf("⚠️  加载事件订阅失败（以空订阅启动）: %v\n", err)
+	}
+	h.subscriptions = subscriptions
+	h.notifier = h.newNotifier()
+
 	// 初始化解析器
 	h.registerParsers()
 	
@@ -67,11 +98,30 @@ func (h *Handler) Health(c *gin.Context) {
 	})
 }
 
+// ListSchemas 列出已发布的消息JSON Schema
+func (h *Handler) ListSchemas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"schema_version": model.SchemaVersion,
+		"data":           schema.List(),
+	})
+}
+
+// GetSchema 获取请求/响应消息的JSON Schema
+func (h *Handler) GetSchema(c *gin.Context) {
+	data, ok := schema.Get(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schema不存在"})
+		return
+	}
+	c.Data(http.StatusOK, "application/schema+json", data)
+}
+
 // ListProcessors 获取处理器列表
 func (h *Handler) ListProcessors(c *gin.Context) {
 	processors := h.configMgr.GetProcessors()
 	c.JSON(http.StatusOK, gin.H{
-		"data": processors,
+		"data":   processors,
+		"groups": h.configMgr.GetGroups(),
 	})
 }
 
@@ -97,7 +147,13 @@ func (h *Handler) Command(c *gin.Context) {
 		return
 	}
 
-	// 3. 处理消息
+	// 3. 处理消息，请求异步执行时立即返回202
+	if isAsyncRequest(c, body) {
+		h.deduplicate(c, msg, func() (int, gin.H) {
+			return h.processAsync(c, msg)
+		})
+		return
+	}
 	h.processMessage(c, msg)
 }
 
@@ -171,114 +227,75 @@ func (h *Handler) ReloadConfig(c *gin.Context) {
 }
 
 // processMessage 处理统一消息的核心逻辑
+// Telegram消息的后端进度通过编辑状态消息展示
 func (h *Handler) processMessage(c *gin.Context, msg *model.UnifiedMessage) {
-	ctx := c.Request.Context()
+	h.deduplicate(c, msg, func() (int, gin.H) {
+		ctx, progress := h.withTelegramProgress(c.Request.Context(), msg)
+		status, body := h.handleMessage(ctx, requestTraceID(c), msg)
+		if progress != nil {
+			progress.finish(body)
+		}
+		return status, body
+	})
+}
+
+// requestTraceID 获取请求的TraceID，中间件未设置时生成新的
+func requestTraceID(c *gin.Context) string {
 	traceID := c.GetString("trace_id")
 	if traceID == "" {
 		traceID = uuid.New().String()
 	}
+	return traceID
+}
 
+// handleMessage 识别并执行消息中的指令，返回响应状态码和响应体
+func (h *Handler) handleMessage(ctx context.Context, traceID string, msg *model.UnifiedMessage) (int, gin.H) {
 	fmt.Printf("[%s] 收到消息: %s (来自: %s)\n", traceID, msg.Content, msg.Channel)
 
-	// 1. LLM 意图识别 (匹配处理器)
-	processors := h.configMgr.GetProcessors()
-	matchResult, err := h.llmClient.MatchProcessors(ctx, msg.Content, processors)
-	if err != nil {
-		fmt.Printf("[%s] LLM匹配失败: %v\n", traceID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "意图识别服务异常"})
-		return
-	}
-
-	if len(matchResult.Matches) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "抱歉，我没有理解您的指令，或者没有找到对应的功能。",
-			"trace_id": traceID,
-		})
-		return
+	// 输入安全检查（长度、注入特征），会话中的回答同样需要检查
+	if rejected := h.checkInput(traceID, msg.Content); rejected != nil {
+		return commandResultBody(rejected)
 	}
 
-	// 取置信度最高的匹配
-	bestMatch := matchResult.Matches[0]
-	fmt.Printf("[%s] 匹配处理器: %s (置信度: %.2f)\n", traceID, bestMatch.ProcessorID, bestMatch.Confidence)
-
-	// 获取处理器详情
-	processor := h.configMgr.GetProcessor(bestMatch.ProcessorID)
-	if processor == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理器配置不存在"})
-		return
+	// 存在等待补充参数的会话时，本条消息作为回答处理，不再重新识别意图；
+	// 回答明显是一条新指令时会话结束，继续按新指令处理
+	if sess := h.sessions.Get(session.KeyOf(msg)); sess != nil {
+		if result := h.continueSession(ctx, traceID, msg, sess); result != nil {
+			return commandResultBody(result)
+		}
 	}
 
-	// 2. LLM 参数提取
-	paramResult, err := h.llmClient.ExtractParameters(ctx, msg.Content, *processor)
+	// 1. 意图拆分（一句话可能包含多条指令）
+	commands, err := h.llmClient.SplitIntents(ctx, msg.Content)
 	if err != nil {
-		fmt.Printf("[%s] 参数提取失败: %v\n", traceID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "参数解析服务异常"})
-		return
-	}
-
-	if !paramResult.Success {
-		c.JSON(http.StatusOK, gin.H{
-			"message": fmt.Sprintf("指令不完整: %s", paramResult.Message),
-			"missing_params": paramResult.MissingRequired,
-			"trace_id": traceID,
-		})
-		return
-	}
-
-	fmt.Printf("[%s] 提取参数: %v\n", traceID, paramResult.Parameters)
-
-	// 3. 发送请求到Kafka (如果有Kafka客户端)
-	if h.kafkaClient == nil {
-		// 无Kafka模式，直接返回模拟成功
-		c.JSON(http.StatusOK, gin.H{
-			"message": fmt.Sprintf("已识别指令：使用 [%s] 执行操作，参数：%v (演示模式，未发送到后端)", 
-				processor.Name, paramResult.Parameters),
-			"processor": processor.Name,
-			"parameters": paramResult.Parameters,
-			"trace_id": traceID,
-		})
-		return
+		// 拆分失败不影响整体处理，按单条指令继续
+		fmt.Printf("[%s] 意图拆分失败，按单条指令处理: %v\n", traceID, err)
+		commands = []string{msg.Content}
 	}
 
-	kafkaReq := &model.KafkaRequest{
-		TraceID:     traceID,
-		ProcessorID: processor.ID,
-		Parameters:  paramResult.Parameters,
-		RawMessage:  *msg,
-		CreatedAt:   time.Now(),
+	if len(commands) <= 1 {
+		result := h.executeCommand(ctx, traceID, "", msg, msg.Content)
+		switch {
+		case len(result.Choices) > 0:
+			// 匹配有歧义时请用户选择
+			h.startChoiceSession(msg, result)
+		case len(result.MissingParams) > 0:
+			// 缺少必填参数时进入多轮追问
+			h.startSession(msg, result)
+		}
+		return commandResultBody(result)
 	}
 
-	resp, err := h.kafkaClient.SendAndWait(kafkaReq)
-	if err != nil {
-		fmt.Printf("[%s] 后端处理超时或失败: %v\n", traceID, err)
-		c.JSON(http.StatusGatewayTimeout, gin.H{
-			"error": "后端服务响应超时",
-			"trace_id": traceID,
-		})
-		return
-	}
+	fmt.Printf("[%s] 拆分为%d条子指令: %v\n", traceID, len(commands), commands)
 
-	// 4. 返回结果
-	if !resp.Success {
-		c.JSON(http.StatusOK, gin.H{
-			"message": fmt.Sprintf("执行失败: %s", resp.Error),
-			"trace_id": traceID,
-		})
-		return
-	}
-
-	// 如果Result是字符串，直接显示，如果是结构体，序列化
-	msgResult := "操作成功"
-	if resultStr, ok := resp.Result.(string); ok {
-		msgResult = resultStr
-	} else {
-		bytes, _ := json.Marshal(resp.Result)
-		msgResult = string(bytes)
+	// 2. 按顺序逐条执行子指令，子指令共享父TraceID
+	results := make([]*commandResult, 0, len(commands))
+	for i, cmd := range commands {
+		subTraceID := fmt.Sprintf("%s-%d", traceID, i+1)
+		result := h.executeCommand(ctx, subTraceID, traceID, msg, cmd)
+		result.Index = i + 1
+		results = append(results, result)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": msgResult,
-		"data": resp.Result,
-		"trace_id": traceID,
-	})
+	return multiCommandResultBody(traceID, results)
 }
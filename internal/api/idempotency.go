@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yoyo3287258/home-gateway/internal/idempotency"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/session"
+)
+
+// idempotencyKey 消息的幂等键：Telegram 使用 update_id，HTTP 使用 Idempotency-Key 请求头
+// 键按接口、渠道、会话和用户区分，不同客户端使用相同的键不会拿到彼此的结果；
+// 没有可用的键时返回空字符串，不去重
+func idempotencyKey(c *gin.Context, msg *model.UnifiedMessage) string {
+	var id string
+	switch msg.Channel {
+	case model.ChannelTelegram:
+		if v, ok := msg.RawData["update_id"]; ok {
+			id = fmt.Sprint(v)
+		}
+	case model.ChannelHTTP:
+		id = c.GetHeader("Idempotency-Key")
+	}
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s|%s|%s", c.FullPath(), session.KeyOf(msg), id)
+}
+
+// deduplicate 以幂等键去重执行 process 并输出响应
+// 重复的消息（如Telegram在处理较慢时重试的Webhook）不会再次发送到后端，
+// 而是等待首次处理完成并返回相同的结果
+func (h *Handler) deduplicate(c *gin.Context, msg *model.UnifiedMessage, process func() (int, gin.H)) {
+	status, body, replayed := h.runIdempotent(c.Request.Context(), idempotencyKey(c, msg), process)
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+	}
+	writeResult(c, status, body)
+}
+
+// runIdempotent 以幂等键去重执行 process，返回响应和是否为重复请求，key 为空时直接执行
+// 返回的响应体可能与其他请求共用，修改前需要复制
+func (h *Handler) runIdempotent(ctx context.Context, key string, process func() (int, gin.H)) (int, gin.H, bool) {
+	if key == "" {
+		status, body := process()
+		return status, body, false
+	}
+
+	result, duplicate, err := h.idempotency.Do(ctx, key, func() idempotency.Result {
+		status, body := process()
+		return idempotency.Result{Status: status, Body: body}
+	})
+	if err != nil {
+		return http.StatusConflict, gin.H{"error": "相同的请求正在处理中"}, true
+	}
+	if duplicate {
+		fmt.Printf("[%s] 重复的消息，返回已有结果\n", key)
+	}
+	return result.Status, result.Body.(gin.H), duplicate
+}
+
+// writeResult 输出响应，异步受理的响应附带结果查询地址
+func writeResult(c *gin.Context, status int, body gin.H) {
+	if url, ok := body["status_url"].(string); ok && status == http.StatusAccepted {
+		c.Header("Location", url)
+	}
+	c.JSON(status, body)
+}
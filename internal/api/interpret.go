@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// Interpretation 单条指令的识别结果（未下发到后端）
+type Interpretation struct {
+	// Content 指令内容（拆分后的子指令）
+	Content string
+
+	// ProcessorID 匹配到的处理器ID，没有明确匹配时为空
+	ProcessorID string
+
+	// Confidence 所选处理器的匹配置信度
+	Confidence float64
+
+	// Parameters 提取（并校验）后的参数
+	Parameters map[string]interface{}
+
+	// Choices 匹配有歧义时的候选处理器ID
+	Choices []string
+
+	// Message 未能执行时面向用户的提示（输入被拦截、缺少参数、参数无效等）
+	Message string
+
+	// Error 网关内部错误（LLM异常等）
+	Error string
+}
+
+// Interpret 按网关处理消息的流程识别指令：输入检查 -> 意图拆分 -> 意图识别 -> 歧义判断 -> 敏感检查 -> 参数提取与校验
+// 不下发到后端，也不创建追问会话，供评测等离线场景使用
+func (h *Handler) Interpret(ctx context.Context, msg *model.UnifiedMessage) []Interpretation {
+	traceID := uuid.New().String()
+
+	if rejected := h.checkInput(traceID, msg.Content); rejected != nil {
+		return []Interpretation{interpretationOf(rejected)}
+	}
+
+	commands, err := h.llmClient.SplitIntents(ctx, msg.Content)
+	if err != nil {
+		fmt.Printf("[%s] 意图拆分失败，按单条指令处理: %v\n", traceID, err)
+	}
+	if len(commands) <= 1 {
+		commands = []string{msg.Content}
+	}
+
+	out := make([]Interpretation, 0, len(commands))
+	for i, cmd := range commands {
+		result := &commandResult{Content: cmd, TraceID: traceID}
+		if len(commands) > 1 {
+			result.TraceID = fmt.Sprintf("%s-%d", traceID, i+1)
+		}
+		h.resolveCommand(ctx, result, msg, len(commands) > 1)
+		out = append(out, interpretationOf(result))
+	}
+	return out
+}
+
+// interpretationOf 把处理结果转换为识别结果
+func interpretationOf(result *commandResult) Interpretation {
+	it := Interpretation{
+		Content:     result.Content,
+		ProcessorID: result.ProcessorID,
+		Confidence:  result.confidence,
+		Parameters:  result.Parameters,
+		Message:     result.Message,
+		Error:       result.Error,
+	}
+	for _, c := range result.Choices {
+		it.Choices = append(it.Choices, c.ProcessorID)
+	}
+	return it
+}
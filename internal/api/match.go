@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// matchChoice 匹配有歧义时提供给用户的候选处理器
+type matchChoice struct {
+	ProcessorID string  `json:"processor_id"`
+	Name        string  `json:"name"`
+	Confidence  float64 `json:"confidence"`
+}
+
+// matchLogEntry 低置信度/歧义匹配记录，用于调整处理器描述
+type matchLogEntry struct {
+	Time    time.Time              `json:"time"`
+	TraceID string                 `json:"trace_id"`
+	Content string                 `json:"content"`
+	Outcome string                 `json:"outcome"` // low_confidence, ambiguous, unknown_processor
+	Matches []model.ProcessorMatch `json:"matches"`
+}
+
+// matchLogMu 保护低置信度记录文件的并发写入
+var matchLogMu sync.Mutex
+
+// matchProcessors 意图识别
+// 开启 matching.group_routing 时先选择分组，再只在分组内匹配处理器
+func (h *Handler) matchProcessors(ctx context.Context, traceID, content string) (*model.ProcessorMatchResult, error) {
+	processors := h.configMgr.GetProcessors()
+	cfg := h.configMgr.Get().Matching
+	if !cfg.GroupRouting {
+		return h.llmClient.MatchProcessors(ctx, content, processors)
+	}
+
+	result, err := h.llmClient.RouteProcessors(ctx, content, h.configMgr.GetGroups(), processors, *cfg.MinConfidence, *cfg.AmbiguityMargin)
+	if err == nil && len(result.Groups) > 0 {
+		fmt.Printf("[%s] 分组路由: %s\n", traceID, strings.Join(result.Groups, ", "))
+	}
+	return result, err
+}
+
+// selectProcessor 按置信度阈值和歧义规则从匹配结果中选择处理器
+// 返回 (处理器, nil) 表示有明确的匹配；(nil, 候选) 表示需要用户选择；(nil, nil) 表示没有可用匹配
+func (h *Handler) selectProcessor(traceID, content string, matches []model.ProcessorMatch) (*model.Processor, []matchChoice) {
+	cfg := h.configMgr.Get().Matching
+
+	// LLM返回的结果不保证有序
+	sorted := make([]model.ProcessorMatch, len(matches))
+	copy(sorted, matches)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Confidence > sorted[j].Confidence
+	})
+
+	type candidate struct {
+		match     model.ProcessorMatch
+		processor *model.Processor
+	}
+	var candidates []candidate
+	for _, m := range sorted {
+		processor := h.configMgr.GetProcessor(m.ProcessorID)
+		if processor == nil || !processor.Enabled {
+			h.logMatchIssue(traceID, content, "unknown_processor", []model.ProcessorMatch{m})
+			continue
+		}
+		threshold := *cfg.MinConfidence
+		if processor.MinConfidence > 0 {
+			threshold = processor.MinConfidence
+		}
+		if m.Confidence < threshold {
+			continue
+		}
+		candidates = append(candidates, candidate{match: m, processor: processor})
+	}
+
+	if len(candidates) == 0 {
+		if len(sorted) > 0 {
+			h.logMatchIssue(traceID, content, "low_confidence", sorted)
+		}
+		return nil, nil
+	}
+
+	top := candidates[0]
+	if len(candidates) == 1 || top.match.Confidence-candidates[1].match.Confidence >= *cfg.AmbiguityMargin {
+		return top.processor, nil
+	}
+
+	// 前几个候选过于接近，交给用户选择
+	var choices []matchChoice
+	var ambiguous []model.ProcessorMatch
+	for _, cand := range candidates {
+		if top.match.Confidence-cand.match.Confidence >= *cfg.AmbiguityMargin || len(choices) >= cfg.MaxChoices {
+			break
+		}
+		choices = append(choices, matchChoice{
+			ProcessorID: cand.processor.ID,
+			Name:        cand.processor.Name,
+			Confidence:  cand.match.Confidence,
+		})
+		ambiguous = append(ambiguous, cand.match)
+	}
+	h.logMatchIssue(traceID, content, "ambiguous", ambiguous)
+
+	return nil, choices
+}
+
+// logMatchIssue 记录低置信度/歧义匹配及其原因
+func (h *Handler) logMatchIssue(traceID, content, outcome string, matches []model.ProcessorMatch) {
+	var reasons []string
+	for _, m := range matches {
+		reasons = append(reasons, fmt.Sprintf("%s(%.2f: %s)", m.ProcessorID, m.Confidence, m.Reason))
+	}
+	fmt.Printf("[%s] 匹配未采用 [%s]: %s -> %s\n", traceID, outcome, content, strings.Join(reasons, "; "))
+
+	path := h.configMgr.Get().Matching.LowConfidenceLog
+	if path == "" {
+		return
+	}
+
+	line, err := json.Marshal(matchLogEntry{
+		Time:    time.Now(),
+		TraceID: traceID,
+		Content: content,
+		Outcome: outcome,
+		Matches: matches,
+	})
+	if err != nil {
+		return
+	}
+
+	matchLogMu.Lock()
+	defer matchLogMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("[%s] 写入低置信度记录失败: %v\n", traceID, err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// choiceNames 候选处理器名称列表
+func choiceNames(choices []matchChoice) []string {
+	names := make([]string, 0, len(choices))
+	for _, c := range choices {
+		names = append(names, c.Name)
+	}
+	return names
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/reply"
+)
+
+// renderReply 把后端返回的结果转换为面向用户的回复，按渠道选择格式
+// 依次尝试：处理器的 reply 模板、字符串结果原样返回、LLM生成（reply.llm_fallback）、结果的JSON
+func (h *Handler) renderReply(ctx context.Context, traceID string, processor *model.Processor, msg *model.UnifiedMessage, params map[string]interface{}, data interface{}) (string, reply.Format) {
+	format := reply.FormatFor(msg.Channel)
+
+	text, ok, err := reply.Render(processor, reply.Data{
+		Processor:  *processor,
+		Parameters: params,
+		Result:     data,
+		Channel:    msg.Channel,
+	}, format)
+	if err != nil {
+		fmt.Printf("[%s] %v\n", traceID, err)
+	}
+	if ok && text != "" {
+		return text, format
+	}
+
+	switch v := data.(type) {
+	case nil:
+		return "操作成功", reply.FormatPlain
+	case string:
+		return v, reply.FormatPlain
+	}
+
+	if h.configMgr.Get().Reply.LLMFallback {
+		text, err := h.llmClient.SummarizeResult(ctx, *processor, params, data, format == reply.FormatMarkdown)
+		if err == nil {
+			return text, format
+		}
+		fmt.Printf("[%s] %v\n", traceID, err)
+	}
+
+	bytes, _ := json.Marshal(data)
+	return string(bytes), reply.FormatPlain
+}
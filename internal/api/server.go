@@ -57,6 +57,10 @@ func (s *Server) setupRoutes() {
 		// 健康检查（不需要认证）
 		v1.GET("/health", s.handler.Health)
 
+		// 请求/响应消息的JSON Schema（不需要认证，供后端校验消息格式）
+		v1.GET("/schemas", s.handler.ListSchemas)
+		v1.GET("/schemas/:name", s.handler.GetSchema)
+
 		// 需要API Token认证的接口
 		protected := v1.Group("")
 		protected.Use(APITokenAuthMiddleware(&cfg.Security))
@@ -67,6 +71,19 @@ func (s *Server) setupRoutes() {
 			// 通用命令接口
 			protected.POST("/command", s.handler.Command)
 
+			// 以Server-Sent Events返回处理进度的指令接口
+			protected.POST("/command/stream", s.handler.CommandStream)
+
+			// 异步指令结果查询
+			protected.GET("/requests/:trace_id", s.handler.GetRequest)
+
+			// 设备事件订阅
+			protected.GET("/subscriptions", s.handler.ListSubscriptions)
+			protected.POST("/subscriptions", s.handler.CreateSubscription)
+			protected.DELETE("/subscriptions/:id", s.handler.DeleteSubscription)
+			protected.GET("/subscriptions/settings", s.handler.GetSubscriptionSettings)
+			protected.PUT("/subscriptions/settings", s.handler.UpdateSubscriptionSettings)
+
 			// 配置重载
 			protected.POST("/config/reload", s.handler.ReloadConfig)
 		}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/normalize"
+	"github.com/yoyo3287258/home-gateway/internal/session"
+)
+
+// slotAnswerMaxLen 直接作为参数值的回答的最大字数
+const slotAnswerMaxLen = 10
+
+// actionVerbs 回答中出现这些词时说明用户在下达新指令，而不是回答追问
+var actionVerbs = []string{
+	"打开", "关闭", "关掉", "开启", "启动", "停止", "暂停", "播放", "调到", "调成", "调高", "调低", "调亮", "调暗",
+	"开到", "设置", "设为", "设成", "改成", "切换", "提醒", "帮我", "把", "算了",
+}
+
+// isSlotAnswer 判断回答是否像是单独的参数值（如追问房间时回答"客厅"）
+func isSlotAnswer(answer string) bool {
+	if len([]rune(answer)) > slotAnswerMaxLen {
+		return false
+	}
+	for _, verb := range actionVerbs {
+		if strings.Contains(answer, verb) {
+			return false
+		}
+	}
+	return true
+}
+
+// startSession 指令缺少必填参数时创建会话，并把结果改写为追问
+func (h *Handler) startSession(msg *model.UnifiedMessage, result *commandResult) {
+	processor := h.configMgr.GetProcessor(result.ProcessorID)
+	if processor == nil {
+		return
+	}
+
+	params := result.Parameters
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	missing := session.MissingRequired(processor, params)
+	if len(missing) == 0 {
+		// LLM认为不完整但必填参数其实都在，不进入追问
+		return
+	}
+
+	sess := &session.Session{
+		Key:         session.KeyOf(msg),
+		TraceID:     result.TraceID,
+		ProcessorID: processor.ID,
+		Content:     result.Content,
+		Parameters:  params,
+		Missing:     missing,
+	}
+	h.sessions.Save(sess)
+
+	fmt.Printf("[%s] 指令缺少参数 %v，等待用户补充\n", result.TraceID, missing)
+	result.MissingParams = missing
+	result.Message = session.Question(processor, sess.Asking())
+}
+
+// startChoiceSession 意图匹配有歧义时创建会话，请用户从候选中选择
+func (h *Handler) startChoiceSession(msg *model.UnifiedMessage, result *commandResult) {
+	sess := &session.Session{
+		Key:               session.KeyOf(msg),
+		TraceID:           result.TraceID,
+		Content:           result.Content,
+		ChoiceConfidences: make(map[string]float64),
+	}
+	lines := []string{"您的指令可能对应以下功能，请回复序号选择："}
+	for i, choice := range result.Choices {
+		sess.Choices = append(sess.Choices, choice.ProcessorID)
+		sess.ChoiceConfidences[choice.ProcessorID] = choice.Confidence
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, choice.Name))
+	}
+	lines = append(lines, "回复“取消”可结束本次操作。")
+	h.sessions.Save(sess)
+
+	result.Message = strings.Join(lines, "\n")
+}
+
+// resolveChoice 根据用户的回答确定所选处理器，继续执行原指令
+func (h *Handler) resolveChoice(ctx context.Context, result *commandResult, msg *model.UnifiedMessage, sess *session.Session, answer string) *commandResult {
+	var chosen *model.Processor
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(sess.Choices) {
+		chosen = h.configMgr.GetProcessor(sess.Choices[n-1])
+	} else {
+		for _, id := range sess.Choices {
+			p := h.configMgr.GetProcessor(id)
+			if p != nil && (strings.EqualFold(answer, p.ID) || strings.Contains(answer, p.Name)) {
+				chosen = p
+				break
+			}
+		}
+	}
+
+	if chosen == nil {
+		// 没有识别出选择，保持会话并重新提示
+		h.sessions.Save(sess)
+		result.Message = fmt.Sprintf("没有识别出您的选择，请回复 1-%d 之间的序号，或回复“取消”。", len(sess.Choices))
+		return result
+	}
+
+	h.sessions.Cancel(sess.Key)
+	fmt.Printf("[%s] 用户选择处理器: %s (会话: %s)\n", result.TraceID, chosen.ID, sess.TraceID)
+
+	// 用户的选择不提高匹配置信度，敏感处理器仍按原始置信度检查，不能借歧义提示绕过 sensitive_min_confidence
+	if !h.checkSensitive(result, chosen, sess.ChoiceConfidences[chosen.ID], false) {
+		return result
+	}
+
+	h.runWithProcessor(ctx, result, chosen, msg, sess.TraceID)
+	if len(result.MissingParams) > 0 {
+		h.startSession(msg, result)
+	}
+	return result
+}
+
+// continueSession 把用户的回答合并到进行中的会话
+// 回答没有提供任何参数且看起来是一条新指令时结束会话并返回nil，由调用方按新指令处理
+func (h *Handler) continueSession(ctx context.Context, traceID string, msg *model.UnifiedMessage, sess *session.Session) *commandResult {
+	result := &commandResult{
+		Content: sess.Content,
+		TraceID: traceID,
+		status:  http.StatusOK,
+	}
+
+	answer := strings.TrimSpace(msg.Content)
+	if h.isCancelKeyword(answer) {
+		h.sessions.Cancel(sess.Key)
+		fmt.Printf("[%s] 用户取消会话 (发起于: %s)\n", traceID, sess.TraceID)
+		result.Message = "好的，已取消本次操作。"
+		return result
+	}
+
+	if len(sess.Choices) > 0 {
+		return h.resolveChoice(ctx, result, msg, sess, answer)
+	}
+
+	processor := h.configMgr.GetProcessor(sess.ProcessorID)
+	if processor == nil {
+		// 配置重载后处理器可能已被删除
+		h.sessions.Cancel(sess.Key)
+		result.status = http.StatusInternalServerError
+		result.Error = "处理器配置不存在"
+		return result
+	}
+	result.ProcessorID = processor.ID
+	result.Processor = processor.Name
+
+	fmt.Printf("[%s] 补充参数 (会话: %s, 处理器: %s): %s\n", traceID, sess.TraceID, processor.ID, answer)
+
+	fill, err := h.llmClient.FillParameters(ctx, normalize.Text(answer), *processor, sess.Parameters, sess.Missing)
+	if err != nil {
+		fmt.Printf("[%s] 参数补全失败: %v\n", traceID, err)
+		result.status = http.StatusInternalServerError
+		result.Error = "参数解析服务异常"
+		return result
+	}
+
+	for name, value := range fill.Parameters {
+		sess.Parameters[name] = value
+	}
+
+	asking := sess.Asking()
+	if _, ok := sess.Parameters[asking]; !ok {
+		switch {
+		case isSlotAnswer(answer):
+			// LLM没有提取到正在追问的字符串参数时，直接把简短的回答作为参数值（如追问房间时回答"客厅"）
+			for _, p := range processor.Parameters {
+				if p.Name == asking && p.Type == "string" {
+					sess.Parameters[asking] = answer
+				}
+			}
+		case len(fill.Parameters) == 0:
+			// 如追问房间时回答"算了把空调开到26度"，结束追问，按新指令处理
+			h.sessions.Cancel(sess.Key)
+			fmt.Printf("[%s] 回答不是参数值，结束会话 (发起于: %s) 并作为新指令处理\n", traceID, sess.TraceID)
+			return nil
+		}
+	}
+
+	result.Parameters = sess.Parameters
+	sess.Missing = session.MissingRequired(processor, sess.Parameters)
+	if len(sess.Missing) > 0 {
+		h.sessions.Save(sess)
+		result.MissingParams = sess.Missing
+		result.Message = session.Question(processor, sess.Asking())
+		return result
+	}
+
+	h.sessions.Cancel(sess.Key)
+	fmt.Printf("[%s] 参数已补全: %v\n", traceID, sess.Parameters)
+
+	params, err := h.validateParameters(ctx, traceID, sess.Content+" "+answer, processor, sess.Parameters)
+	if err != nil {
+		result.Message = fmt.Sprintf("参数无效: %v", err)
+		return result
+	}
+	result.Parameters = params
+
+	h.dispatchCommand(ctx, result, processor, msg, sess.TraceID)
+	return result
+}
+
+// isCancelKeyword 判断用户输入是否为取消会话的关键词
+func (h *Handler) isCancelKeyword(s string) bool {
+	for _, kw := range h.configMgr.Get().Session.CancelKeywords {
+		if strings.EqualFold(s, kw) {
+			return true
+		}
+	}
+	return false
+}
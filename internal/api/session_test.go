@@ -0,0 +1,36 @@
+package api
+
+import "testing"
+
+func TestIsSlotAnswer(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		// 单独的参数值
+		{"客厅", true},
+		{"26", true},
+		{"26度", true},
+		{"制冷模式", true},
+		{"主卧的那个", true},
+		{"一二三四五六七八九十", true},
+
+		// 超过长度上限
+		{"一二三四五六七八九十一", false},
+		{"我也不知道应该是哪个房间", false},
+
+		// 包含动作词，是新指令
+		{"打开卧室灯", false},
+		{"把电视关了", false},
+		{"关掉", false},
+		{"帮我开空调", false},
+		{"调高一点", false},
+		{"算了", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSlotAnswer(tt.answer); got != tt.want {
+			t.Errorf("isSlotAnswer(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yoyo3287258/home-gateway/internal/channel"
+	"github.com/yoyo3287258/home-gateway/internal/dispatch"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/reply"
+)
+
+// CommandStream 以Server-Sent Events返回指令的处理过程
+// 事件依次为 accepted、后端的中间消息（progress / partial），最后是 result（与同步接口的响应体相同）
+// 带 Idempotency-Key 的重复请求不会再次执行，只返回首次处理的 result（另含 "replayed": true）
+func (h *Handler) CommandStream(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法读取请求体"})
+		return
+	}
+	msg, err := h.parsers["http"].Parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("解析请求失败: %v", err)})
+		return
+	}
+
+	traceID := requestTraceID(c)
+	// 最终结果通过 done 返回，不会丢失；客户端读取太慢时丢弃最早的中间消息，保留最新进度
+	events := make(chan *model.KafkaResponse, 32)
+	ctx := dispatch.WithProgress(c.Request.Context(), func(resp *model.KafkaResponse) {
+		for {
+			select {
+			case events <- resp:
+				return
+			default:
+			}
+			select {
+			case <-events:
+			default:
+			}
+		}
+	})
+
+	type outcome struct {
+		status   int
+		body     gin.H
+		replayed bool
+	}
+	key := idempotencyKey(c, msg)
+	done := make(chan outcome, 1)
+	go func() {
+		status, body, replayed := h.runIdempotent(ctx, key, func() (int, gin.H) {
+			return h.handleMessage(ctx, traceID, msg)
+		})
+		done <- outcome{status, body, replayed}
+	}()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("accepted", gin.H{"trace_id": traceID})
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case resp := <-events:
+			c.SSEvent(resp.Status, resp)
+			return true
+		case o := <-done:
+			// 先发出已收到的中间消息，保证顺序
+			for len(events) > 0 {
+				resp := <-events
+				c.SSEvent(resp.Status, resp)
+			}
+			// 响应体可能被重复请求共用，复制后再添加字段
+			result := gin.H{"status": o.status}
+			for k, v := range o.body {
+				result[k] = v
+			}
+			if o.replayed {
+				result["replayed"] = true
+			}
+			c.SSEvent("result", result)
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// telegramProgress 把后端的中间消息以Telegram消息编辑的方式展示
+// 第一条中间消息发送一条状态消息，之后的消息和最终结果都编辑这条消息
+type telegramProgress struct {
+	bot     *channel.TelegramBot
+	chatID  string
+	replyTo int
+
+	mu        sync.Mutex
+	messageID int
+	lastText  string
+}
+
+// withTelegramProgress 为Telegram消息设置进度回调，未配置 bot_token 时返回nil
+func (h *Handler) withTelegramProgress(ctx context.Context, msg *model.UnifiedMessage) (context.Context, *telegramProgress) {
+	if msg.Channel != model.ChannelTelegram || msg.ChatID == "" {
+		return ctx, nil
+	}
+	bot := h.telegramBot()
+	if bot == nil {
+		return ctx, nil
+	}
+
+	p := &telegramProgress{bot: bot, chatID: msg.ChatID}
+	if id, ok := msg.RawData["message_id"].(int); ok {
+		p.replyTo = id
+	}
+	return dispatch.WithProgress(ctx, p.update), p
+}
+
+// update 展示一条中间消息
+func (p *telegramProgress) update(resp *model.KafkaResponse) {
+	p.show(progressText(resp), "")
+}
+
+// finish 把状态消息编辑为最终结果；没有发送过状态消息时不处理
+func (p *telegramProgress) finish(body gin.H) {
+	p.mu.Lock()
+	sent := p.messageID != 0
+	p.mu.Unlock()
+	if !sent {
+		return
+	}
+
+	// 回复模板按 MarkdownV2 渲染时（format 为 markdown）需要带上 parse_mode，否则会显示转义字符
+	text, _ := body["message"].(string)
+	parseMode := ""
+	if format, _ := body["format"].(reply.Format); format == reply.FormatMarkdown {
+		parseMode = channel.TelegramParseModeMarkdownV2
+	}
+	if errText, ok := body["error"].(string); ok && errText != "" {
+		text, parseMode = "❌ "+errText, ""
+	}
+	if text != "" {
+		p.show(text, parseMode)
+	}
+}
+
+// show 发送或编辑状态消息，内容不变时跳过（Telegram拒绝内容相同的编辑）
+// 按 parseMode 解析失败时（如LLM生成的Markdown不合法）退回纯文本
+func (p *telegramProgress) show(text, parseMode string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if text == p.lastText {
+		return
+	}
+
+	err := p.send(text, parseMode)
+	if err != nil && parseMode != "" {
+		fmt.Printf("以 %s 格式更新Telegram消息失败，改为纯文本: %v\n", parseMode, err)
+		err = p.send(text, "")
+	}
+	if err != nil {
+		fmt.Printf("更新Telegram进度消息失败: %v\n", err)
+		return
+	}
+	p.lastText = text
+}
+
+// send 发送或编辑状态消息（调用方需持有锁）
+func (p *telegramProgress) send(text, parseMode string) error {
+	if p.messageID == 0 {
+		id, err := p.bot.SendMessage(p.chatID, text, p.replyTo, parseMode)
+		if err != nil {
+			return err
+		}
+		p.messageID = id
+		return nil
+	}
+	return p.bot.EditMessageText(p.chatID, p.messageID, text, parseMode)
+}
+
+// progressText 中间消息的展示文本
+func progressText(resp *model.KafkaResponse) string {
+	text := "⏳ 处理中"
+	if resp.Percent > 0 {
+		text = fmt.Sprintf("⏳ 处理中 %.0f%%", resp.Percent)
+	}
+
+	switch result := resp.Result.(type) {
+	case nil:
+	case string:
+		text += "\n" + result
+	default:
+		if data, err := json.Marshal(result); err == nil {
+			text += "\n" + string(data)
+		}
+	}
+	return text
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yoyo3287258/home-gateway/internal/channel"
+	"github.com/yoyo3287258/home-gateway/internal/events"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// HandleEvent 把后端上报的设备事件推送给订阅的用户
+func (h *Handler) HandleEvent(event *model.DeviceEvent) {
+	fmt.Printf("[事件 %s] 收到设备事件: %s (处理器: %s)\n", event.ID, event.Type, event.ProcessorID)
+	h.notifier.Handle(event)
+}
+
+// newNotifier 创建事件推送器，所有事件共用
+func (h *Handler) newNotifier() *events.Notifier {
+	notifier := events.NewNotifier(h.subscriptions, func(processorID string) string {
+		if p := h.configMgr.GetProcessor(processorID); p != nil {
+			return p.Group
+		}
+		return ""
+	}, h.now)
+
+	notifier.AddSender(string(model.ChannelTelegram), func(sub events.Subscription, text string) error {
+		bot := h.telegramBot()
+		if bot == nil {
+			return fmt.Errorf("未配置 channels.telegram.bot_token")
+		}
+		chatID := sub.ChatID
+		if chatID == "" {
+			chatID = sub.UserID
+		}
+		_, err := bot.SendMessage(chatID, text, 0, "")
+		return err
+	})
+	return notifier
+}
+
+// telegramBot 返回当前 bot_token 的Telegram客户端，未配置时返回nil
+func (h *Handler) telegramBot() *channel.TelegramBot {
+	token := h.configMgr.Get().Channels.Telegram.BotToken
+	if token == "" {
+		return nil
+	}
+
+	h.botMu.Lock()
+	defer h.botMu.Unlock()
+	if h.bot == nil || h.botToken != token {
+		h.bot = channel.NewTelegramBot(token)
+		h.botToken = token
+	}
+	return h.bot
+}
+
+// ListSubscriptions 列出事件订阅，可按 channel、user_id 过滤
+func (h *Handler) ListSubscriptions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.subscriptions.List(c.Query("channel"), c.Query("user_id")),
+	})
+}
+
+// CreateSubscription 创建事件订阅
+func (h *Handler) CreateSubscription(c *gin.Context) {
+	var sub events.Subscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("解析请求失败: %v", err)})
+		return
+	}
+
+	created, err := h.subscriptions.Add(sub)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteSubscription 删除事件订阅
+func (h *Handler) DeleteSubscription(c *gin.Context) {
+	ok, err := h.subscriptions.Remove(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "订阅已删除"})
+}
+
+// settingsRequest 用户推送设置请求
+type settingsRequest struct {
+	Channel string `json:"channel" binding:"required"`
+	UserID  string `json:"user_id" binding:"required"`
+	events.Settings
+}
+
+// GetSubscriptionSettings 获取用户的推送设置（静音、免打扰时段）
+func (h *Handler) GetSubscriptionSettings(c *gin.Context) {
+	channelName, userID := c.Query("channel"), c.Query("user_id")
+	if channelName == "" || userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel 和 user_id 不能为空"})
+		return
+	}
+	c.JSON(http.StatusOK, h.subscriptions.Settings(channelName, userID))
+}
+
+// UpdateSubscriptionSettings 更新用户的推送设置
+func (h *Handler) UpdateSubscriptionSettings(c *gin.Context) {
+	var req settingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("解析请求失败: %v", err)})
+		return
+	}
+
+	if err := h.subscriptions.SetSettings(req.Channel, req.UserID, req.Settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, req.Settings)
+}
@@ -18,6 +18,7 @@ func (p *HTTPParser) Name() string {
 type HTTPRequest struct {
 	Content string                 `json:"content"`
 	UserID  string                 `json:"user_id"`
+	ChatID  string                 `json:"chat_id"`
 	RawData map[string]interface{} `json:"raw_data"`
 }
 
@@ -36,5 +37,5 @@ func (p *HTTPParser) Parse(rawData []byte) (*model.UnifiedMessage, error) {
 		req.UserID = "anonymous"
 	}
 
-	return model.NewUnifiedMessage(req.Content, model.ChannelHTTP, req.UserID, "", req.RawData), nil
+	return model.NewUnifiedMessage(req.Content, model.ChannelHTTP, req.UserID, req.ChatID, req.RawData), nil
 }
@@ -92,6 +92,9 @@ func (p *TelegramParser) Parse(rawData []byte) (*model.UnifiedMessage, error) {
 	if msg.From != nil {
 		rawMap["from_username"] = msg.From.Username
 		rawMap["from_name"] = msg.From.FirstName + " " + msg.From.LastName
+		if msg.From.LanguageCode != "" {
+			rawMap["language_code"] = msg.From.LanguageCode
+		}
 	}
 
 	return model.NewUnifiedMessage(msg.Text, model.ChannelTelegram, userID, chatID, rawMap), nil
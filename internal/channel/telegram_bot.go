@@ -0,0 +1,89 @@
+package channel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TelegramParseModeMarkdownV2 Telegram MarkdownV2 格式，文本中的特殊字符需要转义（见 reply.EscapeMarkdown）
+const TelegramParseModeMarkdownV2 = "MarkdownV2"
+
+// TelegramBot Telegram Bot API客户端（发送和编辑消息）
+type TelegramBot struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewTelegramBot 创建Bot API客户端
+func NewTelegramBot(token string) *TelegramBot {
+	return &TelegramBot{
+		baseURL:    "https://api.telegram.org/bot" + token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SendMessage 发送消息，返回消息ID；parseMode 为空时按纯文本发送
+func (b *TelegramBot) SendMessage(chatID, text string, replyTo int, parseMode string) (int, error) {
+	params := map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}
+	if replyTo > 0 {
+		params["reply_to_message_id"] = replyTo
+	}
+	if parseMode != "" {
+		params["parse_mode"] = parseMode
+	}
+
+	var msg TelegramMessage
+	if err := b.call("sendMessage", params, &msg); err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+// EditMessageText 修改已发送消息的内容；parseMode 为空时按纯文本显示
+func (b *TelegramBot) EditMessageText(chatID string, messageID int, text string, parseMode string) error {
+	params := map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       text,
+	}
+	if parseMode != "" {
+		params["parse_mode"] = parseMode
+	}
+	return b.call("editMessageText", params, nil)
+}
+
+// call 调用Bot API方法
+func (b *TelegramBot) call(method string, params map[string]interface{}, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.httpClient.Post(b.baseURL+"/"+method, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("调用Telegram %s 失败: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("解析Telegram %s 响应失败: %w", method, err)
+	}
+	if !apiResp.OK {
+		return fmt.Errorf("Telegram %s 失败: %s", method, apiResp.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(apiResp.Result, result)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -26,9 +27,36 @@ type Config struct {
 	// Kafka Kafka配置
 	Kafka KafkaConfig `yaml:"kafka"`
 
+	// Dispatch 指令分发配置（Kafka以外的后端）
+	Dispatch DispatchConfig `yaml:"dispatch"`
+
 	// Channels 渠道配置
 	Channels ChannelsConfig `yaml:"channels"`
 
+	// Session 多轮对话配置
+	Session SessionConfig `yaml:"session"`
+
+	// Matching 意图匹配配置
+	Matching MatchingConfig `yaml:"matching"`
+
+	// Guard 输入安全检查配置
+	Guard GuardConfig `yaml:"guard"`
+
+	// PromptContext 参数提取时提供给LLM的上下文配置
+	PromptContext PromptContextConfig `yaml:"prompt_context"`
+
+	// Reply 回复生成配置
+	Reply ReplyConfig `yaml:"reply"`
+
+	// Async 异步指令配置
+	Async AsyncConfig `yaml:"async"`
+
+	// Events 设备事件推送配置
+	Events EventsConfig `yaml:"events"`
+
+	// Idempotency 重复消息去重配置
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+
 	// Log 日志配置
 	Log LogConfig `yaml:"log"`
 }
@@ -67,6 +95,9 @@ type ServerConfig struct {
 
 // LLMConfig 大语言模型配置
 type LLMConfig struct {
+	// Provider LLM提供方: openai（默认，OpenAI兼容接口）, mock（内置规则模拟，无需外部服务）
+	Provider string `yaml:"provider"`
+
 	// BaseURL API基础URL（OpenAI兼容格式）
 	BaseURL string `yaml:"base_url"`
 
@@ -81,6 +112,28 @@ type LLMConfig struct {
 
 	// MaxRetries 最大重试次数
 	MaxRetries int `yaml:"max_retries"`
+
+	// ValidationReprompt 参数校验失败时是否把错误反馈给LLM重新提取一次
+	ValidationReprompt bool `yaml:"validation_reprompt"`
+
+	// PromptsDir 提示词模板覆盖目录，目录下的 <name>.tmpl 会替换同名内置模板
+	// 可覆盖的模板：match, extract, split, fill, correct
+	PromptsDir string `yaml:"prompts_dir"`
+
+	// MaxMatchExamples 意图匹配时每个处理器最多提供的few-shot示例数
+	MaxMatchExamples int `yaml:"max_match_examples"`
+
+	// Cassette LLM请求录制/回放配置
+	Cassette CassetteConfig `yaml:"cassette"`
+}
+
+// CassetteConfig LLM请求录制/回放配置
+type CassetteConfig struct {
+	// Mode 模式: 留空-关闭, record-录制, replay-回放（不访问网络，未录制的请求直接报错）
+	Mode string `yaml:"mode"`
+
+	// Path 录制文件路径（JSONL）
+	Path string `yaml:"path"`
 }
 
 // KafkaConfig Kafka配置
@@ -97,8 +150,163 @@ type KafkaConfig struct {
 	// ConsumerGroup 消费者组
 	ConsumerGroup string `yaml:"consumer_group"`
 
+	// InstanceID 网关实例ID，多副本部署时每个实例必须不同，默认为主机名
+	InstanceID string `yaml:"instance_id"`
+
+	// ReplyTopic 本实例的响应topic，支持 {instance} 占位符（如 home.response.{instance}）
+	// 设置后请求中会携带 reply_to，后端按它回复，响应只会到达正在等待的实例；为空时所有实例共用 response_topic
+	ReplyTopic string `yaml:"reply_topic"`
+
+	// TopicReplicationFactor 网关自动创建响应topic时的副本数，为0时使用broker的 default.replication.factor
+	TopicReplicationFactor int16 `yaml:"topic_replication_factor"`
+
 	// ResponseTimeout 响应超时时间
 	ResponseTimeout time.Duration `yaml:"response_timeout"`
+
+	// Outbox Kafka不可用时的本地请求队列
+	Outbox OutboxConfig `yaml:"outbox"`
+
+	// CloudEvents CloudEvents消息格式配置
+	CloudEvents CloudEventsConfig `yaml:"cloudevents"`
+
+	// ClientID 客户端ID（broker日志和配额使用），默认为 sarama
+	ClientID string `yaml:"client_id"`
+
+	// Version 固定的Kafka协议版本（如 2.8.0），为空时使用sarama的默认版本
+	Version string `yaml:"version"`
+
+	// TLS TLS配置
+	TLS KafkaTLSConfig `yaml:"tls"`
+
+	// SASL SASL认证配置
+	SASL KafkaSASLConfig `yaml:"sasl"`
+}
+
+// CloudEventsConfig CloudEvents 1.0 binary模式配置
+// 启用后请求消息体不变，事件属性通过 ce_* 消息头携带；响应始终同时接受旧格式和CloudEvents格式
+type CloudEventsConfig struct {
+	// Enabled 是否以CloudEvents格式发送请求
+	Enabled bool `yaml:"enabled"`
+
+	// Source 事件来源（ce_source），默认为 /home-gateway/<实例ID>
+	Source string `yaml:"source"`
+
+	// SchemaBaseURL 消息JSON Schema的地址前缀（ce_dataschema），默认为 /api/v1/schemas
+	SchemaBaseURL string `yaml:"schema_base_url"`
+}
+
+// KafkaTLSConfig Kafka TLS配置
+type KafkaTLSConfig struct {
+	// Enabled 是否启用TLS
+	Enabled bool `yaml:"enabled"`
+
+	// CAFile CA证书（PEM），为空时使用系统证书
+	CAFile string `yaml:"ca_file"`
+
+	// CertFile 客户端证书（PEM），与 KeyFile 一起用于双向TLS
+	CertFile string `yaml:"cert_file"`
+
+	// KeyFile 客户端私钥（PEM）
+	KeyFile string `yaml:"key_file"`
+
+	// ServerName 校验证书时使用的服务器名称，为空时使用broker地址
+	ServerName string `yaml:"server_name"`
+
+	// InsecureSkipVerify 跳过服务端证书校验（仅用于测试）
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// KafkaSASLConfig Kafka SASL认证配置
+type KafkaSASLConfig struct {
+	// Mechanism 认证机制: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512，为空时不启用
+	Mechanism string `yaml:"mechanism"`
+
+	// Username 用户名
+	Username string `yaml:"username"`
+
+	// Password 密码
+	Password string `yaml:"password"`
+}
+
+// OutboxConfig 本地请求队列配置
+// 处理器设置 dispatch.queue 时，无法送达Kafka的请求写入本地队列，恢复后按顺序重放
+type OutboxConfig struct {
+	// Dir 队列目录，为空时不启用
+	Dir string `yaml:"dir"`
+
+	// SegmentSize 单个段文件的最大字节数
+	SegmentSize int64 `yaml:"segment_size"`
+
+	// RetryInterval 重连Kafka和重放队列的间隔
+	RetryInterval time.Duration `yaml:"retry_interval"`
+
+	// MaxAge 请求的最长排队时间，超过后不再发送；默认10分钟，设置为负数表示不过期
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// ReplyTopicName 本实例实际使用的响应topic
+func (k *KafkaConfig) ReplyTopicName() string {
+	if k.ReplyTopic == "" {
+		return k.ResponseTopic
+	}
+	return strings.ReplaceAll(k.ReplyTopic, "{instance}", k.InstanceID)
+}
+
+// ConsumerGroupName 本实例使用的消费者组，每个实例各自成组（<consumer_group>-<instance_id>）
+// 共用响应topic时保证每个实例都能收到所有响应（响应只对发出请求的实例有用）；
+// 按实例区分响应topic时，避免某个实例上下线引起所有实例重平衡、暂停消费响应
+func (k *KafkaConfig) ConsumerGroupName() string {
+	return k.ConsumerGroup + "-" + k.InstanceID
+}
+
+// DispatchConfig 指令分发配置
+type DispatchConfig struct {
+	// Backend 默认分发后端: kafka, http, mqtt, local
+	// 处理器可通过 dispatch.backend 单独指定；为空时使用kafka（未配置kafka时为演示模式）
+	Backend string `yaml:"backend"`
+
+	// HTTP HTTP后端配置
+	HTTP HTTPDispatchConfig `yaml:"http"`
+
+	// MQTT MQTT后端配置
+	MQTT MQTTConfig `yaml:"mqtt"`
+}
+
+// HTTPDispatchConfig HTTP后端配置
+// 请求以JSON POST到处理器的 dispatch.url，响应体为 KafkaResponse 格式的JSON
+type HTTPDispatchConfig struct {
+	// Timeout 默认响应超时时间
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Headers 附加的请求头（如 Authorization）
+	Headers map[string]string `yaml:"headers"`
+}
+
+// MQTTConfig MQTT后端配置
+type MQTTConfig struct {
+	// Broker MQTT broker地址（如 tcp://localhost:1883）
+	Broker string `yaml:"broker"`
+
+	// ClientID 客户端ID，默认为 home-gateway-<实例ID>
+	ClientID string `yaml:"client_id"`
+
+	// Username 用户名
+	Username string `yaml:"username"`
+
+	// Password 密码
+	Password string `yaml:"password"`
+
+	// RequestTopic 请求topic，处理器可通过 dispatch.topic 覆盖
+	RequestTopic string `yaml:"request_topic"`
+
+	// ReplyTopic 本实例的响应topic，支持 {instance} 占位符，请求中通过 reply_to 携带
+	ReplyTopic string `yaml:"reply_topic"`
+
+	// QoS 消息服务质量等级（0, 1, 2）
+	QoS byte `yaml:"qos"`
+
+	// ResponseTimeout 默认响应超时时间
+	ResponseTimeout time.Duration `yaml:"response_timeout"`
 }
 
 // ChannelsConfig 渠道配置
@@ -143,6 +351,107 @@ type WeChatWorkConfig struct {
 	EncodingAESKey string `yaml:"encoding_aes_key"`
 }
 
+// SessionConfig 多轮对话配置
+type SessionConfig struct {
+	// Timeout 会话超时时间，超时后未补全的指令自动作废
+	Timeout time.Duration `yaml:"timeout"`
+
+	// CancelKeywords 取消会话的关键词
+	CancelKeywords []string `yaml:"cancel_keywords"`
+}
+
+// MatchingConfig 意图匹配配置
+type MatchingConfig struct {
+	// MinConfidence 全局最低置信度，低于该值的匹配不会被采用；未设置时为0.5，设置为0表示不按置信度过滤
+	// 处理器可以通过 min_confidence 单独覆盖
+	MinConfidence *float64 `yaml:"min_confidence"`
+
+	// AmbiguityMargin 歧义判定阈值，前两个候选的置信度差小于该值时请用户选择；未设置时为0.1，设置为0表示不做歧义判定
+	AmbiguityMargin *float64 `yaml:"ambiguity_margin"`
+
+	// MaxChoices 歧义时最多提供的候选数
+	MaxChoices int `yaml:"max_choices"`
+
+	// LowConfidenceLog 低置信度/歧义匹配的记录文件（JSONL），为空则只输出到控制台
+	LowConfidenceLog string `yaml:"low_confidence_log"`
+
+	// GroupRouting 是否启用分组优先的两阶段匹配：先由LLM选择分组，再只在分组内匹配处理器
+	// 处理器较多时可以缩短提示词，并减少不同分组中相似处理器之间的混淆
+	GroupRouting bool `yaml:"group_routing"`
+}
+
+// GuardConfig 输入安全检查配置
+// 用于拦截提示词注入，并对门锁、安防等敏感处理器采用更严格的匹配条件
+type GuardConfig struct {
+	// MaxInputLength 用户输入的最大长度（字数），超过时直接拒绝
+	MaxInputLength int `yaml:"max_input_length"`
+
+	// Patterns 额外的注入特征（正则表达式），命中时拒绝
+	Patterns []string `yaml:"patterns"`
+
+	// SensitiveMinConfidence 匹配敏感处理器所需的最低置信度
+	SensitiveMinConfidence float64 `yaml:"sensitive_min_confidence"`
+
+	// SensitiveMaxLength 敏感处理器指令的最大长度（字数）
+	SensitiveMaxLength int `yaml:"sensitive_max_length"`
+}
+
+// PromptContextConfig 参数提取时提供给LLM的上下文配置
+// 当前时间、用户语言和设备最近状态会渲染到提示词中，用于换算"再亮一点"等相对说法
+type PromptContextConfig struct {
+	// Providers 启用的上下文提供者: time, locale, device_state
+	Providers []string `yaml:"providers"`
+
+	// Timezone 时区（如 Asia/Shanghai），为空时使用本地时区
+	Timezone string `yaml:"timezone"`
+
+	// Locale 默认的用户语言/地区，消息中没有携带时使用
+	Locale string `yaml:"locale"`
+
+	// StateTTL 设备状态的有效期，超过后不再提供给LLM
+	StateTTL time.Duration `yaml:"state_ttl"`
+}
+
+// ReplyConfig 回复生成配置
+type ReplyConfig struct {
+	// LLMFallback 后端返回结构化结果且处理器没有配置 reply 模板时，是否调用LLM生成自然语言回复
+	// 关闭时直接返回结果的JSON
+	LLMFallback bool `yaml:"llm_fallback"`
+}
+
+// AsyncConfig 异步指令配置
+// 异步指令立即返回202，结果通过 GET /api/v1/requests/:trace_id 查询
+type AsyncConfig struct {
+	// Timeout 异步指令等待后端响应的超时时间，超过后状态为 expired
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Retention 结束后结果的保留时间
+	Retention time.Duration `yaml:"retention"`
+}
+
+// EventsConfig 设备事件推送配置
+// 后端把设备事件发到事件topic，网关按用户的订阅通过其所在渠道推送
+type EventsConfig struct {
+	// Enabled 是否消费事件topic
+	Enabled bool `yaml:"enabled"`
+
+	// Topic 事件topic
+	Topic string `yaml:"topic"`
+
+	// ConsumerGroup 事件消费者组，所有实例共用，每个事件只推送一次
+	ConsumerGroup string `yaml:"consumer_group"`
+
+	// StoreFile 订阅和用户设置（免打扰等）的保存文件
+	StoreFile string `yaml:"store_file"`
+}
+
+// IdempotencyConfig 重复消息去重配置
+// 以渠道的消息ID（Telegram update_id、HTTP Idempotency-Key 请求头）去重，重复的消息返回已有结果
+type IdempotencyConfig struct {
+	// TTL 处理结果的保留时间，超过后相同的键会被重新处理
+	TTL time.Duration `yaml:"ttl"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	// Level 日志级别: debug, info, warn, error
@@ -154,6 +463,12 @@ type LogConfig struct {
 
 // ProcessorsConfig 处理器配置
 type ProcessorsConfig struct {
+	// Group 文件级的分组定义，文件中未设置group的处理器归入该分组
+	Group *model.Group `yaml:"group,omitempty"`
+
+	// Groups 分组定义列表（单文件中定义多个分组时使用；加载后包含所有分组）
+	Groups []model.Group `yaml:"groups,omitempty"`
+
 	// Processors 处理器列表
 	Processors []model.Processor `yaml:"processors"`
 }
@@ -232,7 +547,7 @@ func (m *Manager) loadProcessors(path string) (*ProcessorsConfig, error) {
 		return nil, err
 	}
 
-	var allProcessors []model.Processor
+	var files []*ProcessorsConfig
 
 	if info.IsDir() {
 		// 目录模式：加载目录下所有yaml文件
@@ -251,26 +566,64 @@ func (m *Manager) loadProcessors(path string) (*ProcessorsConfig, error) {
 			}
 
 			filePath := path + "/" + name
-			processors, err := m.loadSingleProcessorFile(filePath)
+			file, err := m.loadSingleProcessorFile(filePath)
 			if err != nil {
 				return nil, fmt.Errorf("加载处理器文件 %s 失败: %w", name, err)
 			}
-			allProcessors = append(allProcessors, processors...)
+			files = append(files, file)
 		}
 	} else {
 		// 单文件模式
-		processors, err := m.loadSingleProcessorFile(path)
+		file, err := m.loadSingleProcessorFile(path)
 		if err != nil {
 			return nil, err
 		}
-		allProcessors = processors
+		files = append(files, file)
 	}
 
-	return &ProcessorsConfig{Processors: allProcessors}, nil
+	return mergeProcessorFiles(files), nil
+}
+
+// mergeProcessorFiles 合并多个处理器文件
+// 同一分组在多个文件中定义时，先出现的字段优先；处理器引用了但没有定义的分组以ID作为名称
+func mergeProcessorFiles(files []*ProcessorsConfig) *ProcessorsConfig {
+	merged := &ProcessorsConfig{}
+	index := make(map[string]int)
+
+	addGroup := func(g model.Group) {
+		i, ok := index[g.ID]
+		if !ok {
+			index[g.ID] = len(merged.Groups)
+			merged.Groups = append(merged.Groups, g)
+			return
+		}
+		existing := &merged.Groups[i]
+		if existing.Name == "" {
+			existing.Name = g.Name
+		}
+		if existing.Description == "" {
+			existing.Description = g.Description
+		}
+		existing.Keywords = append(existing.Keywords, g.Keywords...)
+	}
+
+	for _, file := range files {
+		for _, g := range file.Groups {
+			addGroup(g)
+		}
+		merged.Processors = append(merged.Processors, file.Processors...)
+	}
+	for _, p := range merged.Processors {
+		if _, ok := index[p.Group]; !ok && p.Group != "" {
+			addGroup(model.Group{ID: p.Group, Name: p.Group})
+		}
+	}
+
+	return merged
 }
 
 // loadSingleProcessorFile 加载单个处理器配置文件
-func (m *Manager) loadSingleProcessorFile(path string) ([]model.Processor, error) {
+func (m *Manager) loadSingleProcessorFile(path string) (*ProcessorsConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -289,11 +642,23 @@ func (m *Manager) loadSingleProcessorFile(path string) ([]model.Processor, error
 		// 移除 -processors 后缀
 		defaultGroup = strings.TrimSuffix(defaultGroup, "-processors")
 		defaultGroup = strings.TrimSuffix(defaultGroup, "_processors")
+
+		// 文件级分组定义：未设置id时使用文件名推断的分组
+		if config.Group != nil {
+			if config.Group.ID == "" {
+				config.Group.ID = defaultGroup
+			}
+			defaultGroup = config.Group.ID
+			config.Groups = append([]model.Group{*config.Group}, config.Groups...)
+		}
 		
 		for i := range config.Processors {
 			if config.Processors[i].Group == "" {
 				config.Processors[i].Group = defaultGroup
 			}
+			if err := config.Processors[i].Dispatch.Validate(); err != nil {
+				return nil, fmt.Errorf("处理器 %s 的分发策略无效: %w", config.Processors[i].ID, err)
+			}
 			// 默认启用
 			if !config.Processors[i].Enabled {
 				// YAML中未设置时，bool默认为false，这里需要特殊处理
@@ -302,7 +667,7 @@ func (m *Manager) loadSingleProcessorFile(path string) ([]model.Processor, error
 		}
 	}
 
-	return config.Processors, nil
+	return &config, nil
 }
 
 // Reload 重新加载配置
@@ -390,6 +755,16 @@ func (m *Manager) GetProcessors() []model.Processor {
 	return m.processors.Processors
 }
 
+// GetGroups 获取处理器分组列表
+func (m *Manager) GetGroups() []model.Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.processors == nil {
+		return nil
+	}
+	return m.processors.Groups
+}
+
 // GetProcessor 根据ID获取处理器
 func (m *Manager) GetProcessor(id string) *model.Processor {
 	m.mu.RLock()
@@ -437,6 +812,9 @@ func setDefaults(config *Config) {
 	if config.LLM.MaxRetries == 0 {
 		config.LLM.MaxRetries = 3
 	}
+	if config.LLM.MaxMatchExamples == 0 {
+		config.LLM.MaxMatchExamples = 2
+	}
 
 	if config.Kafka.ResponseTimeout == 0 {
 		config.Kafka.ResponseTimeout = 5 * time.Second
@@ -450,6 +828,110 @@ func setDefaults(config *Config) {
 	if config.Kafka.ResponseTopic == "" {
 		config.Kafka.ResponseTopic = "home.response"
 	}
+	if config.Kafka.ConsumerGroup == "" {
+		config.Kafka.ConsumerGroup = "home-gateway"
+	}
+	if config.Kafka.InstanceID == "" {
+		config.Kafka.InstanceID, _ = os.Hostname()
+		if config.Kafka.InstanceID == "" {
+			config.Kafka.InstanceID = "gateway"
+		}
+	}
+
+	if config.Kafka.CloudEvents.Source == "" {
+		config.Kafka.CloudEvents.Source = "/home-gateway/" + config.Kafka.InstanceID
+	}
+	if config.Kafka.CloudEvents.SchemaBaseURL == "" {
+		config.Kafka.CloudEvents.SchemaBaseURL = "/api/v1/schemas"
+	}
+
+	if config.Kafka.Outbox.SegmentSize == 0 {
+		config.Kafka.Outbox.SegmentSize = 16 << 20
+	}
+	if config.Kafka.Outbox.MaxAge == 0 {
+		config.Kafka.Outbox.MaxAge = 10 * time.Minute
+	}
+	if config.Kafka.Outbox.RetryInterval == 0 {
+		config.Kafka.Outbox.RetryInterval = 10 * time.Second
+	}
+
+	if config.Dispatch.HTTP.Timeout == 0 {
+		config.Dispatch.HTTP.Timeout = config.Kafka.ResponseTimeout
+	}
+	if config.Dispatch.MQTT.ClientID == "" {
+		config.Dispatch.MQTT.ClientID = "home-gateway-" + config.Kafka.InstanceID
+	}
+	if config.Dispatch.MQTT.RequestTopic == "" {
+		config.Dispatch.MQTT.RequestTopic = "home/request"
+	}
+	if config.Dispatch.MQTT.ReplyTopic == "" {
+		config.Dispatch.MQTT.ReplyTopic = "home/response/{instance}"
+	}
+	config.Dispatch.MQTT.ReplyTopic = strings.ReplaceAll(config.Dispatch.MQTT.ReplyTopic, "{instance}", config.Kafka.InstanceID)
+	if config.Dispatch.MQTT.ResponseTimeout == 0 {
+		config.Dispatch.MQTT.ResponseTimeout = config.Kafka.ResponseTimeout
+	}
+
+	if config.Session.Timeout == 0 {
+		config.Session.Timeout = 2 * time.Minute
+	}
+	if len(config.Session.CancelKeywords) == 0 {
+		config.Session.CancelKeywords = []string{"取消", "算了", "不用了", "cancel"}
+	}
+
+	// 0是有效值（关闭对应功能），只有未设置时才使用默认值
+	if config.Matching.MinConfidence == nil {
+		minConfidence := 0.5
+		config.Matching.MinConfidence = &minConfidence
+	}
+	if config.Matching.AmbiguityMargin == nil {
+		margin := 0.1
+		config.Matching.AmbiguityMargin = &margin
+	}
+	if config.Matching.MaxChoices == 0 {
+		config.Matching.MaxChoices = 3
+	}
+
+	if config.Guard.MaxInputLength == 0 {
+		config.Guard.MaxInputLength = 200
+	}
+	if config.Guard.SensitiveMinConfidence == 0 {
+		config.Guard.SensitiveMinConfidence = 0.9
+	}
+	if config.Guard.SensitiveMaxLength == 0 {
+		config.Guard.SensitiveMaxLength = 50
+	}
+
+	if config.PromptContext.Providers == nil {
+		config.PromptContext.Providers = []string{"time", "locale", "device_state"}
+	}
+	if config.PromptContext.Locale == "" {
+		config.PromptContext.Locale = "zh-CN"
+	}
+	if config.PromptContext.StateTTL == 0 {
+		config.PromptContext.StateTTL = 24 * time.Hour
+	}
+
+	if config.Async.Timeout == 0 {
+		config.Async.Timeout = 10 * time.Minute
+	}
+	if config.Async.Retention == 0 {
+		config.Async.Retention = time.Hour
+	}
+
+	if config.Events.Topic == "" {
+		config.Events.Topic = "home.events"
+	}
+	if config.Events.ConsumerGroup == "" {
+		config.Events.ConsumerGroup = config.Kafka.ConsumerGroup + "-events"
+	}
+	if config.Events.StoreFile == "" {
+		config.Events.StoreFile = "data/subscriptions.json"
+	}
+
+	if config.Idempotency.TTL == 0 {
+		config.Idempotency.TTL = 10 * time.Minute
+	}
 
 	if config.Log.Level == "" {
 		config.Log.Level = "info"
@@ -463,18 +945,78 @@ func setDefaults(config *Config) {
 func (c *Config) Validate() error {
 	var errs []string
 
-	if c.LLM.BaseURL == "" {
-		errs = append(errs, "llm.base_url 不能为空")
+	// mock和回放模式不访问LLM服务，不需要地址和密钥
+	switch c.LLM.Provider {
+	case "", "openai", "mock":
+	default:
+		errs = append(errs, fmt.Sprintf("llm.provider 无效: %s（可选: openai, mock）", c.LLM.Provider))
+	}
+	if c.LLM.Provider != "mock" && c.LLM.Cassette.Mode != "replay" {
+		if c.LLM.BaseURL == "" {
+			errs = append(errs, "llm.base_url 不能为空")
+		}
+		if c.LLM.APIKey == "" || strings.HasPrefix(c.LLM.APIKey, "${") {
+			errs = append(errs, "llm.api_key 未设置或环境变量未定义")
+		}
+	}
+	switch c.LLM.Cassette.Mode {
+	case "", "record", "replay":
+	default:
+		errs = append(errs, fmt.Sprintf("llm.cassette.mode 无效: %s（可选: record, replay）", c.LLM.Cassette.Mode))
 	}
-	if c.LLM.APIKey == "" || strings.HasPrefix(c.LLM.APIKey, "${") {
-		errs = append(errs, "llm.api_key 未设置或环境变量未定义")
+	if c.LLM.Cassette.Mode != "" && c.LLM.Cassette.Path == "" {
+		errs = append(errs, "llm.cassette.path 不能为空")
 	}
-	if c.LLM.Model == "" {
+	if c.LLM.Model == "" && c.LLM.Provider != "mock" {
 		errs = append(errs, "llm.model 不能为空")
 	}
 
-	if len(c.Kafka.Brokers) == 0 {
-		errs = append(errs, "kafka.brokers 不能为空")
+	switch c.Dispatch.Backend {
+	case "", model.BackendKafka:
+		if len(c.Kafka.Brokers) == 0 {
+			errs = append(errs, "kafka.brokers 不能为空")
+		}
+	case model.BackendHTTP, model.BackendLocal:
+	case model.BackendMQTT:
+		if c.Dispatch.MQTT.Broker == "" {
+			errs = append(errs, "dispatch.mqtt.broker 不能为空")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("dispatch.backend 无效: %s（可选: kafka, http, mqtt, local）", c.Dispatch.Backend))
+	}
+	switch strings.ToUpper(c.Kafka.SASL.Mechanism) {
+	case "", "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if c.Kafka.SASL.Mechanism != "" && c.Kafka.SASL.Username == "" {
+			errs = append(errs, "kafka.sasl.username 不能为空")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("kafka.sasl.mechanism 无效: %s（可选: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512）", c.Kafka.SASL.Mechanism))
+	}
+	if (c.Kafka.TLS.CertFile == "") != (c.Kafka.TLS.KeyFile == "") {
+		errs = append(errs, "kafka.tls.cert_file 和 kafka.tls.key_file 需要同时设置")
+	}
+
+	if c.Dispatch.MQTT.QoS > 2 {
+		errs = append(errs, "dispatch.mqtt.qos 只能为 0, 1, 2")
+	}
+
+	for _, name := range c.PromptContext.Providers {
+		switch name {
+		case "time", "locale", "device_state":
+		default:
+			errs = append(errs, fmt.Sprintf("prompt_context.providers 无效: %s（可选: time, locale, device_state）", name))
+		}
+	}
+	if c.PromptContext.Timezone != "" {
+		if _, err := time.LoadLocation(c.PromptContext.Timezone); err != nil {
+			errs = append(errs, fmt.Sprintf("prompt_context.timezone 无效: %s", c.PromptContext.Timezone))
+		}
+	}
+
+	for _, pattern := range c.Guard.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Sprintf("guard.patterns 无效: %s (%v)", pattern, err))
+		}
 	}
 
 	if len(errs) > 0 {
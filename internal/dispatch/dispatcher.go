@@ -0,0 +1,160 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/pending"
+)
+
+var (
+	// ErrNotConfigured 处理器使用的后端没有配置（如未配置Kafka），以演示模式运行
+	ErrNotConfigured = errors.New("分发后端未配置")
+
+	// ErrUnavailable 处理器使用的后端已配置但连接失败
+	ErrUnavailable = errors.New("分发后端不可用")
+
+	// ErrMisconfigured 处理器的分发策略配置有误（如HTTP后端未配置 dispatch.url）
+	ErrMisconfigured = errors.New("分发策略配置有误")
+
+	// ErrNotDelivered 请求没有送达后端或后端返回了错误状态
+	ErrNotDelivered = errors.New("请求未送达后端")
+
+	// ErrQueued 后端暂时不可用，请求已写入本地队列，恢复后发送
+	ErrQueued = errors.New("请求已排队，等待后端恢复后发送")
+)
+
+// Dispatcher 指令分发后端
+type Dispatcher interface {
+	// Name 后端名称（kafka, http, mqtt, local）
+	Name() string
+
+	// SendAndWait 发送请求并等待响应
+	// 超时时间依次取 policy.ResponseTimeout、参数 timeout、后端的默认超时；
+	// 策略不等待响应（expect_response: false）时发送成功即返回，响应为nil；
+	// 后端发送的中间消息交给 WithProgress 设置的回调
+	SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error)
+
+	// Close 释放连接
+	Close() error
+}
+
+// Router 按处理器的分发策略选择后端
+type Router struct {
+	defaultBackend string
+	backends       map[string]Dispatcher
+
+	// unavailable 已配置但连接失败的后端及失败原因
+	unavailable map[string]error
+}
+
+// NewRouter 创建路由，defaultBackend 为处理器未指定后端时使用的后端
+func NewRouter(defaultBackend string) *Router {
+	if defaultBackend == "" {
+		defaultBackend = model.BackendKafka
+	}
+	return &Router{
+		defaultBackend: defaultBackend,
+		backends:       make(map[string]Dispatcher),
+		unavailable:    make(map[string]error),
+	}
+}
+
+// Add 注册后端
+func (r *Router) Add(d Dispatcher) {
+	r.backends[d.Name()] = d
+}
+
+// MarkUnavailable 记录已配置但连接失败的后端，使用该后端的请求返回 ErrUnavailable，而不是以演示模式运行
+func (r *Router) MarkUnavailable(name string, err error) {
+	r.unavailable[name] = err
+}
+
+// Has 后端是否可用，name 为空时检查默认后端
+func (r *Router) Has(name string) bool {
+	if name == "" {
+		name = r.defaultBackend
+	}
+	_, ok := r.backends[name]
+	return ok
+}
+
+// DefaultBackend 默认后端名称
+func (r *Router) DefaultBackend() string {
+	return r.defaultBackend
+}
+
+// Name 后端名称
+func (r *Router) Name() string {
+	return "router"
+}
+
+// SendAndWait 按策略中的后端发送请求
+func (r *Router) SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error) {
+	name := policy.Backend
+	if name == "" {
+		name = r.defaultBackend
+	}
+	d, ok := r.backends[name]
+	if !ok {
+		if err, failed := r.unavailable[name]; failed {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnavailable, name, err)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotConfigured, name)
+	}
+	return d.SendAndWait(ctx, req, policy, timeout)
+}
+
+// Close 关闭所有后端
+func (r *Router) Close() error {
+	var errs []error
+	for _, d := range r.backends {
+		if err := d.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("关闭分发后端失败: %v", errs)
+	}
+	return nil
+}
+
+// IsTimeout 错误是否为等待后端响应超时
+func IsTimeout(err error) bool {
+	return errors.Is(err, pending.ErrTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// progressKey 上下文中的进度回调
+type progressKey struct{}
+
+// WithProgress 在上下文中设置进度回调，后端发送的中间消息（进度、部分结果）会交给它处理
+func WithProgress(ctx context.Context, fn func(resp *model.KafkaResponse)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFrom 获取上下文中的进度回调，未设置时为nil
+func progressFrom(ctx context.Context) func(resp *model.KafkaResponse) {
+	fn, _ := ctx.Value(progressKey{}).(func(resp *model.KafkaResponse))
+	return fn
+}
+
+// ReportProgress 报告中间消息，供进程内处理函数使用
+func ReportProgress(ctx context.Context, resp *model.KafkaResponse) {
+	if fn := progressFrom(ctx); fn != nil {
+		fn(resp)
+	}
+}
+
+// effectiveTimeout 计算本次请求的响应超时时间
+func effectiveTimeout(policy model.Dispatch, timeout, fallback time.Duration) time.Duration {
+	if policy.ResponseTimeout > 0 {
+		return policy.ResponseTimeout
+	}
+	if timeout > 0 {
+		return timeout
+	}
+	return fallback
+}
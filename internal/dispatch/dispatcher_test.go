@@ -0,0 +1,164 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// fakeBackend 记录收到的请求，按预设返回响应或错误
+type fakeBackend struct {
+	name     string
+	resp     *model.KafkaResponse
+	err      error
+	closeErr error
+
+	requests []*model.KafkaRequest
+	timeouts []time.Duration
+}
+
+func (f *fakeBackend) Name() string { return f.name }
+
+func (f *fakeBackend) SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error) {
+	f.requests = append(f.requests, req)
+	f.timeouts = append(f.timeouts, effectiveTimeout(policy, timeout, time.Second))
+	ReportProgress(ctx, &model.KafkaResponse{TraceID: req.TraceID, Status: model.ResponseProgress, Sequence: 1})
+	return f.resp, f.err
+}
+
+func (f *fakeBackend) Close() error { return f.closeErr }
+
+func TestRouterSelectsBackend(t *testing.T) {
+	kafka := &fakeBackend{name: model.BackendKafka, resp: &model.KafkaResponse{Success: true}}
+	http := &fakeBackend{name: model.BackendHTTP, resp: &model.KafkaResponse{Success: true}}
+	r := NewRouter("")
+	r.Add(kafka)
+	r.Add(http)
+
+	req := &model.KafkaRequest{TraceID: "t1", ProcessorID: "light"}
+	if _, err := r.SendAndWait(context.Background(), req, model.Dispatch{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.SendAndWait(context.Background(), req, model.Dispatch{Backend: model.BackendHTTP, ResponseTimeout: 3 * time.Second}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(kafka.requests) != 1 || len(http.requests) != 1 {
+		t.Fatalf("kafka 收到 %d 条，http 收到 %d 条，期望各 1 条", len(kafka.requests), len(http.requests))
+	}
+	// 策略中的超时优先
+	if http.timeouts[0] != 3*time.Second {
+		t.Errorf("超时 = %v，期望 3s", http.timeouts[0])
+	}
+	if !r.Has("") || !r.Has(model.BackendHTTP) || r.Has(model.BackendMQTT) {
+		t.Error("Has 结果不正确")
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	r := NewRouter(model.BackendKafka)
+	r.MarkUnavailable(model.BackendMQTT, errors.New("connection refused"))
+	failing := &fakeBackend{name: model.BackendHTTP, err: ErrNotDelivered}
+	r.Add(failing)
+
+	tests := []struct {
+		backend string
+		want    error
+	}{
+		{"", ErrNotConfigured},
+		{model.BackendLocal, ErrNotConfigured},
+		{model.BackendMQTT, ErrUnavailable},
+		{model.BackendHTTP, ErrNotDelivered},
+	}
+	for _, tt := range tests {
+		_, err := r.SendAndWait(context.Background(), &model.KafkaRequest{TraceID: "t1"}, model.Dispatch{Backend: tt.backend}, 0)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("backend %q: err = %v，期望 %v", tt.backend, err, tt.want)
+		}
+	}
+}
+
+func TestRouterProgressAndClose(t *testing.T) {
+	backend := &fakeBackend{name: model.BackendKafka, resp: &model.KafkaResponse{Success: true}, closeErr: errors.New("boom")}
+	r := NewRouter("")
+	r.Add(backend)
+
+	var progress []*model.KafkaResponse
+	ctx := WithProgress(context.Background(), func(resp *model.KafkaResponse) {
+		progress = append(progress, resp)
+	})
+	if _, err := r.SendAndWait(ctx, &model.KafkaRequest{TraceID: "t1"}, model.Dispatch{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 1 || progress[0].TraceID != "t1" {
+		t.Errorf("中间消息 = %v", progress)
+	}
+
+	if err := r.Close(); err == nil {
+		t.Error("后端关闭失败时应返回错误")
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	if !IsTimeout(ctx.Err()) {
+		t.Error("DeadlineExceeded 应视为超时")
+	}
+	if IsTimeout(context.Canceled) || IsTimeout(ErrNotDelivered) {
+		t.Error("取消和未送达不是超时")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	RegisterLocal("test_echo", func(ctx context.Context, req *model.KafkaRequest) (interface{}, error) {
+		return req.Parameters["value"], nil
+	})
+	RegisterLocal("test_fail", func(ctx context.Context, req *model.KafkaRequest) (interface{}, error) {
+		return nil, errors.New("设备离线")
+	})
+	RegisterLocal("test_slow", func(ctx context.Context, req *model.KafkaRequest) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !HasLocal() {
+		t.Fatal("注册后 HasLocal 应为true")
+	}
+
+	l := NewLocal(time.Second)
+	send := func(processorID string, policy model.Dispatch) (*model.KafkaResponse, error) {
+		return l.SendAndWait(context.Background(), &model.KafkaRequest{
+			TraceID:     "t1",
+			ProcessorID: processorID,
+			Parameters:  map[string]interface{}{"value": 42},
+		}, policy, 0)
+	}
+
+	resp, err := send("test_echo", model.Dispatch{})
+	if err != nil || !resp.Success || resp.Result != 42 {
+		t.Errorf("echo: resp = %+v, err = %v", resp, err)
+	}
+
+	resp, err = send("test_fail", model.Dispatch{})
+	if err != nil || resp.Success || resp.Error != "设备离线" {
+		t.Errorf("fail: resp = %+v, err = %v", resp, err)
+	}
+
+	if _, err := send("test_slow", model.Dispatch{ResponseTimeout: 10 * time.Millisecond}); !IsTimeout(err) {
+		t.Errorf("slow: err = %v，期望超时", err)
+	}
+
+	noResponse := false
+	if resp, err := send("test_slow", model.Dispatch{ExpectResponse: &noResponse}); resp != nil || err != nil {
+		t.Errorf("不等待响应: resp = %+v, err = %v", resp, err)
+	}
+
+	if _, err := send("missing", model.Dispatch{}); !errors.Is(err, ErrMisconfigured) {
+		t.Errorf("未注册: err = %v，期望 ErrMisconfigured", err)
+	}
+}
@@ -0,0 +1,98 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// HTTP 以HTTP POST把请求发送到处理器的 dispatch.url
+// 请求体为 KafkaRequest 的JSON，后端在响应体中返回 KafkaResponse 的JSON
+type HTTP struct {
+	client  *http.Client
+	timeout time.Duration
+	headers map[string]string
+}
+
+// NewHTTP 创建HTTP后端
+func NewHTTP(cfg *config.HTTPDispatchConfig) *HTTP {
+	return &HTTP{
+		client:  &http.Client{},
+		timeout: cfg.Timeout,
+		headers: cfg.Headers,
+	}
+}
+
+// Name 后端名称
+func (h *HTTP) Name() string {
+	return model.BackendHTTP
+}
+
+// SendAndWait 发送请求并等待响应
+func (h *HTTP) SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error) {
+	if policy.URL == "" {
+		return nil, fmt.Errorf("%w: 处理器 %s 未配置 dispatch.url", ErrMisconfigured, req.ProcessorID)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, effectiveTimeout(policy, timeout, h.timeout))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, policy.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Trace-ID", req.TraceID)
+	for k, v := range h.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			// 等待响应超时或请求方已取消
+			return nil, fmt.Errorf("等待HTTP响应失败: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("%w: 发送HTTP请求失败: %v", ErrNotDelivered, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取HTTP响应失败: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%w: HTTP后端返回错误状态 %d: %s", ErrNotDelivered, resp.StatusCode, body)
+	}
+
+	if !policy.WantsResponse() {
+		return nil, nil
+	}
+
+	var result model.KafkaResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("%w: 解析HTTP响应失败: %v", ErrNotDelivered, err)
+	}
+	if result.TraceID == "" {
+		result.TraceID = req.TraceID
+	}
+	return &result, nil
+}
+
+// Close 释放空闲连接
+func (h *HTTP) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,81 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+func TestHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		var req model.KafkaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Trace-ID") != req.TraceID || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad headers", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(model.KafkaResponse{Success: true, Result: req.Parameters["room"]})
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/garbage", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	})
+	release := make(chan struct{})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer close(release)
+
+	h := NewHTTP(&config.HTTPDispatchConfig{Timeout: time.Second, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	defer h.Close()
+
+	send := func(policy model.Dispatch) (*model.KafkaResponse, error) {
+		return h.SendAndWait(context.Background(), &model.KafkaRequest{
+			TraceID:     "t1",
+			ProcessorID: "light",
+			Parameters:  map[string]interface{}{"room": "客厅"},
+		}, policy, 0)
+	}
+
+	resp, err := send(model.Dispatch{URL: server.URL + "/ok"})
+	if err != nil || !resp.Success || resp.Result != "客厅" || resp.TraceID != "t1" {
+		t.Errorf("ok: resp = %+v, err = %v", resp, err)
+	}
+
+	noResponse := false
+	if resp, err := send(model.Dispatch{URL: server.URL + "/garbage", ExpectResponse: &noResponse}); resp != nil || err != nil {
+		t.Errorf("不等待响应: resp = %+v, err = %v", resp, err)
+	}
+
+	tests := []struct {
+		name   string
+		policy model.Dispatch
+		check  func(error) bool
+	}{
+		{"no url", model.Dispatch{}, func(err error) bool { return errors.Is(err, ErrMisconfigured) }},
+		{"error status", model.Dispatch{URL: server.URL + "/error"}, func(err error) bool { return errors.Is(err, ErrNotDelivered) }},
+		{"garbage", model.Dispatch{URL: server.URL + "/garbage"}, func(err error) bool { return errors.Is(err, ErrNotDelivered) }},
+		{"unreachable", model.Dispatch{URL: "http://127.0.0.1:1/"}, func(err error) bool { return errors.Is(err, ErrNotDelivered) }},
+		{"timeout", model.Dispatch{URL: server.URL + "/slow", ResponseTimeout: 20 * time.Millisecond}, IsTimeout},
+	}
+	for _, tt := range tests {
+		if _, err := send(tt.policy); !tt.check(err) {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
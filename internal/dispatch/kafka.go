@@ -0,0 +1,177 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/kafka"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/outbox"
+)
+
+// Kafka 通过Kafka请求/响应topic分发
+// 启动时连接失败不会退回演示模式，而是在后台定期重连；
+// 配置了本地队列时，允许排队的处理器（dispatch.queue）在Kafka不可用时写入队列，恢复后按顺序重放
+type Kafka struct {
+	cfg    *config.KafkaConfig
+	outbox *outbox.Outbox
+
+	mu     sync.RWMutex
+	client *kafka.Client
+
+	// wake 有新请求排队时通知后台立即重放，Kafka正常时不必等到下一次重试
+	wake chan struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewKafka 创建Kafka后端
+// client 为nil表示启动时连接失败，后台会按 outbox.retry_interval 重连；box 为nil时不启用本地队列
+func NewKafka(cfg *config.KafkaConfig, client *kafka.Client, box *outbox.Outbox) *Kafka {
+	k := &Kafka{
+		cfg:    cfg,
+		outbox: box,
+		client: client,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go k.maintain()
+	return k
+}
+
+// Name 后端名称
+func (k *Kafka) Name() string {
+	return model.BackendKafka
+}
+
+// SendAndWait 发送请求并等待响应
+func (k *Kafka) SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error) {
+	queueable := policy.Queue && k.outbox != nil
+
+	// 队列中还有未发送的请求时，新的可排队请求排在后面，保证顺序
+	if queueable && !k.outbox.Empty() {
+		return nil, k.enqueue(req, policy)
+	}
+
+	client := k.current()
+	if client == nil {
+		if queueable {
+			return nil, k.enqueue(req, policy)
+		}
+		return nil, fmt.Errorf("%w: Kafka未连接", kafka.ErrNotDelivered)
+	}
+
+	resp, err := client.Dispatch(ctx, req, policy, timeout, progressFrom(ctx))
+	if queueable && errors.Is(err, kafka.ErrNotDelivered) {
+		fmt.Printf("[%s] 发送到Kafka失败，写入本地队列: %v\n", req.TraceID, err)
+		return nil, k.enqueue(req, policy)
+	}
+	return resp, err
+}
+
+// enqueue 写入本地队列
+func (k *Kafka) enqueue(req *model.KafkaRequest, policy model.Dispatch) error {
+	// 重放时没有请求在等待，不需要后端回复
+	req.ReplyTo = ""
+	if err := k.outbox.Append(&outbox.Entry{Request: req, Policy: policy, QueuedAt: time.Now()}); err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrNotDelivered, err)
+	}
+	if k.current() != nil {
+		select {
+		case k.wake <- struct{}{}:
+		default:
+		}
+	}
+	return ErrQueued
+}
+
+// current 当前的Kafka客户端，未连接时为nil
+func (k *Kafka) current() *kafka.Client {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.client
+}
+
+// maintain 后台重连Kafka并重放本地队列
+func (k *Kafka) maintain() {
+	defer close(k.done)
+
+	ticker := time.NewTicker(k.cfg.Outbox.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		k.reconnect()
+		k.replay()
+
+		select {
+		case <-ticker.C:
+		case <-k.wake:
+		case <-k.stop:
+			return
+		}
+	}
+}
+
+// reconnect 未连接时尝试连接Kafka
+func (k *Kafka) reconnect() {
+	if k.current() != nil {
+		return
+	}
+
+	client, err := kafka.NewClient(k.cfg)
+	if err != nil {
+		fmt.Printf("Kafka重连失败，%v后重试: %v\n", k.cfg.Outbox.RetryInterval, err)
+		return
+	}
+
+	k.mu.Lock()
+	k.client = client
+	k.mu.Unlock()
+	fmt.Printf("Kafka已连接: %v\n", k.cfg.Brokers)
+}
+
+// replay 按顺序重放本地队列，遇到发送失败时停止，下次继续
+func (k *Kafka) replay() {
+	client := k.current()
+	if client == nil || k.outbox == nil || k.outbox.Empty() {
+		return
+	}
+
+	sent, err := k.outbox.Replay(func(e *outbox.Entry) error {
+		return client.Producer.SendRequestWith(e.Request, e.Policy)
+	})
+	if sent > 0 {
+		fmt.Printf("已重放本地队列中的%d条请求\n", sent)
+	}
+	if err != nil {
+		fmt.Printf("重放本地队列中断，%v后重试: %v\n", k.cfg.Outbox.RetryInterval, err)
+	}
+}
+
+// Close 停止后台任务并关闭Kafka客户端
+func (k *Kafka) Close() error {
+	close(k.stop)
+	<-k.done
+
+	var errs []error
+	if client := k.current(); client != nil {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if k.outbox != nil {
+		if err := k.outbox.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// LocalFunc 进程内处理函数，返回的结果作为 KafkaResponse.Result
+type LocalFunc func(ctx context.Context, req *model.KafkaRequest) (interface{}, error)
+
+var (
+	localMu    sync.RWMutex
+	localFuncs = make(map[string]LocalFunc)
+)
+
+// RegisterLocal 为处理器注册进程内处理函数，需在启动服务前调用
+func RegisterLocal(processorID string, fn LocalFunc) {
+	localMu.Lock()
+	defer localMu.Unlock()
+	localFuncs[processorID] = fn
+}
+
+// HasLocal 是否注册了进程内处理函数，没有注册时不需要启用进程内后端
+func HasLocal() bool {
+	localMu.RLock()
+	defer localMu.RUnlock()
+	return len(localFuncs) > 0
+}
+
+// Local 调用进程内注册的处理函数，适合不需要独立后端服务的简单处理器和测试
+type Local struct {
+	timeout time.Duration
+}
+
+// NewLocal 创建进程内后端
+func NewLocal(timeout time.Duration) *Local {
+	return &Local{timeout: timeout}
+}
+
+// Name 后端名称
+func (l *Local) Name() string {
+	return model.BackendLocal
+}
+
+// SendAndWait 调用处理函数并返回结果
+func (l *Local) SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error) {
+	localMu.RLock()
+	fn, ok := localFuncs[req.ProcessorID]
+	localMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: 处理器 %s 没有注册进程内处理函数", ErrMisconfigured, req.ProcessorID)
+	}
+
+	if !policy.WantsResponse() {
+		go fn(context.Background(), req)
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, effectiveTimeout(policy, timeout, l.timeout))
+	defer cancel()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := fn(ctx, req)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		resp := &model.KafkaResponse{
+			TraceID:     req.TraceID,
+			ProcessorID: req.ProcessorID,
+			Success:     o.err == nil,
+			Result:      o.result,
+			ProcessedAt: time.Now(),
+		}
+		if o.err != nil {
+			resp.Error = o.err.Error()
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待响应超时: %w", ctx.Err())
+	}
+}
+
+// Close 无需释放资源
+func (l *Local) Close() error {
+	return nil
+}
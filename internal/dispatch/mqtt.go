@@ -0,0 +1,124 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/pending"
+)
+
+// MQTT 通过MQTT请求/响应topic分发
+// 请求发布到 request_topic（或处理器的 dispatch.topic），消息体为 KafkaRequest 的JSON，
+// reply_to 字段为本实例的响应topic，后端把 KafkaResponse 的JSON发布到该topic，按 trace_id 关联
+type MQTT struct {
+	client       mqtt.Client
+	requestTopic string
+	replyTopic   string
+	qos          byte
+	timeout      time.Duration
+	pending      *pending.Registry
+}
+
+// NewMQTT 连接MQTT broker并订阅本实例的响应topic
+func NewMQTT(cfg *config.MQTTConfig) (*MQTT, error) {
+	m := &MQTT{
+		requestTopic: cfg.RequestTopic,
+		replyTopic:   cfg.ReplyTopic,
+		qos:          cfg.QoS,
+		timeout:      cfg.ResponseTimeout,
+		pending:      pending.NewRegistry(),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(10 * time.Second).
+		// 重连后重新订阅响应topic
+		SetOnConnectHandler(func(c mqtt.Client) {
+			token := c.Subscribe(m.replyTopic, m.qos, m.handleMessage)
+			if token.Wait() && token.Error() != nil {
+				fmt.Printf("订阅MQTT响应topic %s 失败: %v\n", m.replyTopic, token.Error())
+			}
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			fmt.Printf("MQTT连接断开，正在重连: %v\n", err)
+		})
+
+	m.client = mqtt.NewClient(opts)
+	token := m.client.Connect()
+	if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		return nil, fmt.Errorf("连接MQTT broker失败: %v", token.Error())
+	}
+
+	return m, nil
+}
+
+// Name 后端名称
+func (m *MQTT) Name() string {
+	return model.BackendMQTT
+}
+
+// handleMessage 处理响应消息
+func (m *MQTT) handleMessage(_ mqtt.Client, msg mqtt.Message) {
+	var resp model.KafkaResponse
+	if err := json.Unmarshal(msg.Payload(), &resp); err != nil {
+		fmt.Printf("解析MQTT响应消息失败 (topic: %s): %v\n", msg.Topic(), err)
+		return
+	}
+
+	if !m.pending.Deliver(&resp) {
+		fmt.Printf("[%s] 收到无人等待的MQTT响应（可能已超时），已忽略\n", resp.TraceID)
+	}
+}
+
+// SendAndWait 发布请求并等待响应
+func (m *MQTT) SendAndWait(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration) (*model.KafkaResponse, error) {
+	topic := policy.Topic
+	if topic == "" {
+		topic = m.requestTopic
+	}
+
+	var waiter *pending.Waiter
+	if policy.WantsResponse() {
+		// 先登记再发送，避免后端响应过快时在登记之前到达
+		waiter = m.pending.Register(req.TraceID)
+		defer m.pending.Unregister(req.TraceID)
+		req.ReplyTo = m.replyTopic
+	} else {
+		req.ReplyTo = ""
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	timeout = effectiveTimeout(policy, timeout, m.timeout)
+	token := m.client.Publish(topic, m.qos, false, data)
+	if !token.WaitTimeout(timeout) {
+		return nil, fmt.Errorf("%w: 发布MQTT消息超时（%v）", ErrNotDelivered, timeout)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("%w: 发布MQTT消息失败: %v", ErrNotDelivered, err)
+	}
+
+	if waiter == nil {
+		return nil, nil
+	}
+
+	return pending.Wait(ctx, waiter, timeout, progressFrom(ctx))
+}
+
+// Close 断开连接
+func (m *MQTT) Close() error {
+	m.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// Sender 通过渠道把文本推送给订阅者
+type Sender func(sub Subscription, text string) error
+
+// Notifier 按订阅把设备事件推送给用户
+type Notifier struct {
+	store   *Store
+	senders map[string]Sender
+
+	// groupOf 查询处理器所属分组
+	groupOf func(processorID string) string
+
+	// now 当前本地时间（用于判断免打扰时段）
+	now func() time.Time
+}
+
+// NewNotifier 创建推送器
+func NewNotifier(store *Store, groupOf func(string) string, now func() time.Time) *Notifier {
+	return &Notifier{
+		store:   store,
+		senders: make(map[string]Sender),
+		groupOf: groupOf,
+		now:     now,
+	}
+}
+
+// AddSender 注册渠道的推送方式，没有注册的渠道不推送
+func (n *Notifier) AddSender(channel string, sender Sender) {
+	n.senders[channel] = sender
+}
+
+// Handle 处理一个事件，推送给所有匹配的订阅者（同一用户只推送一次）
+func (n *Notifier) Handle(event *model.DeviceEvent) {
+	group := ""
+	if n.groupOf != nil {
+		group = n.groupOf(event.ProcessorID)
+	}
+	text := Text(event)
+	now := n.now()
+
+	delivered := make(map[string]bool)
+	for _, sub := range n.store.List("", "") {
+		user := UserKey(sub.Channel, sub.UserID)
+		if delivered[user] || !sub.Matches(event, group) {
+			continue
+		}
+		if reason := n.suppressed(sub, event, now); reason != "" {
+			fmt.Printf("[事件 %s] 不推送给 %s: %s\n", event.ID, user, reason)
+			continue
+		}
+
+		sender, ok := n.senders[sub.Channel]
+		if !ok {
+			fmt.Printf("[事件 %s] 渠道 %s 不支持推送\n", event.ID, sub.Channel)
+			continue
+		}
+		if err := sender(sub, text); err != nil {
+			fmt.Printf("[事件 %s] 推送给 %s 失败: %v\n", event.ID, user, err)
+			continue
+		}
+		delivered[user] = true
+	}
+}
+
+// suppressed 判断用户的设置是否屏蔽该事件，返回原因
+func (n *Notifier) suppressed(sub Subscription, event *model.DeviceEvent, now time.Time) string {
+	settings := n.store.Settings(sub.Channel, sub.UserID)
+	if settings.Muted && (settings.MutedUntil.IsZero() || now.Before(settings.MutedUntil)) {
+		return "已静音"
+	}
+	if event.Severity != model.SeverityCritical && settings.QuietHours != nil && inQuietHours(settings.QuietHours, now) {
+		return "免打扰时段"
+	}
+	return ""
+}
+
+// Matches 事件是否符合订阅条件
+func (s Subscription) Matches(event *model.DeviceEvent, group string) bool {
+	return matchAny(s.EventTypes, event.Type) &&
+		matchAny(s.Groups, group) &&
+		matchAny(s.Processors, event.ProcessorID)
+}
+
+// matchAny 条件为空或包含该值
+func matchAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// inQuietHours 当前时间是否在免打扰时段内
+func inQuietHours(q *QuietHours, now time.Time) bool {
+	start, err1 := parseClock(q.Start)
+	end, err2 := parseClock(q.End)
+	if err1 != nil || err2 != nil || start == end {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// 跨越午夜，如 22:00-07:00
+	return minute >= start || minute < end
+}
+
+// Text 事件的推送文本
+func Text(event *model.DeviceEvent) string {
+	prefix := "🔔"
+	switch event.Severity {
+	case model.SeverityWarning:
+		prefix = "⚠️"
+	case model.SeverityCritical:
+		prefix = "🚨"
+	}
+
+	if event.Message != "" {
+		return prefix + " " + event.Message
+	}
+
+	text := fmt.Sprintf("%s %s", prefix, event.Type)
+	if event.ProcessorID != "" {
+		text += fmt.Sprintf(" (%s)", event.ProcessorID)
+	}
+	if event.Data != nil {
+		if data, err := json.Marshal(event.Data); err == nil {
+			text += "\n" + string(data)
+		}
+	}
+	return text
+}
@@ -0,0 +1,195 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subscription 用户对一类事件的订阅
+// EventTypes、Groups、Processors 都为空时订阅所有事件；否则事件需同时满足各个非空条件
+type Subscription struct {
+	// ID 订阅ID
+	ID string `json:"id"`
+
+	// Channel 推送渠道（如 telegram）
+	Channel string `json:"channel"`
+
+	// UserID 用户ID
+	UserID string `json:"user_id"`
+
+	// ChatID 推送到的会话ID，为空时使用 UserID
+	ChatID string `json:"chat_id,omitempty"`
+
+	// EventTypes 订阅的事件类型
+	EventTypes []string `json:"event_types,omitempty"`
+
+	// Groups 订阅的处理器分组
+	Groups []string `json:"groups,omitempty"`
+
+	// Processors 订阅的处理器
+	Processors []string `json:"processors,omitempty"`
+
+	// CreatedAt 创建时间
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QuietHours 免打扰时段（本地时间，HH:MM），Start 晚于 End 时跨越午夜
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Settings 用户的推送设置
+type Settings struct {
+	// Muted 是否静音（不推送任何事件）
+	Muted bool `json:"muted,omitempty"`
+
+	// MutedUntil 静音截止时间，为零值时 Muted 一直有效
+	MutedUntil time.Time `json:"muted_until,omitempty"`
+
+	// QuietHours 免打扰时段，期间只推送紧急事件
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+}
+
+// Store 订阅和用户设置，保存在JSON文件中
+type Store struct {
+	path string
+
+	mu            sync.RWMutex
+	subscriptions []Subscription
+	settings      map[string]Settings
+}
+
+// storeData 保存文件的格式
+type storeData struct {
+	Subscriptions []Subscription      `json:"subscriptions"`
+	Settings      map[string]Settings `json:"settings"`
+}
+
+// NewStore 加载（文件不存在时新建）订阅存储
+// 文件无法解析时返回空的存储和错误，修改订阅时会覆盖该文件
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, settings: make(map[string]Settings)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("读取订阅文件失败: %w", err)
+	}
+
+	var stored storeData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return s, fmt.Errorf("解析订阅文件失败: %w", err)
+	}
+	s.subscriptions = stored.Subscriptions
+	if stored.Settings != nil {
+		s.settings = stored.Settings
+	}
+	return s, nil
+}
+
+// UserKey 用户在设置中的键（渠道:用户ID）
+func UserKey(channel, userID string) string {
+	return channel + ":" + userID
+}
+
+// Add 添加订阅
+func (s *Store) Add(sub Subscription) (Subscription, error) {
+	if sub.Channel == "" || sub.UserID == "" {
+		return sub, fmt.Errorf("channel 和 user_id 不能为空")
+	}
+	sub.ID = uuid.New().String()
+	sub.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions = append(s.subscriptions, sub)
+	return sub, s.save()
+}
+
+// Remove 删除订阅，订阅不存在时返回false
+func (s *Store) Remove(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sub := range s.subscriptions {
+		if sub.ID == id {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			return true, s.save()
+		}
+	}
+	return false, nil
+}
+
+// List 列出订阅，channel 和 userID 为空时不过滤
+func (s *Store) List(channel, userID string) []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		if (channel == "" || sub.Channel == channel) && (userID == "" || sub.UserID == userID) {
+			result = append(result, sub)
+		}
+	}
+	return result
+}
+
+// Settings 获取用户的推送设置
+func (s *Store) Settings(channel, userID string) Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.settings[UserKey(channel, userID)]
+}
+
+// SetSettings 保存用户的推送设置
+func (s *Store) SetSettings(channel, userID string, settings Settings) error {
+	if settings.QuietHours != nil {
+		if _, err := parseClock(settings.QuietHours.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(settings.QuietHours.End); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[UserKey(channel, userID)] = settings
+	return s.save()
+}
+
+// save 写入文件（调用方需持有锁）
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(storeData{Subscriptions: s.subscriptions, Settings: s.settings}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("创建订阅目录失败: %w", err)
+	}
+
+	// 先写临时文件再替换，避免写入中途崩溃损坏订阅
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("保存订阅失败: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// parseClock 解析 HH:MM，返回当天零点起的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式无效（应为 HH:MM）: %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
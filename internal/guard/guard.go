@@ -0,0 +1,111 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+var (
+	// ErrTooLong 输入超过长度上限
+	ErrTooLong = errors.New("输入过长")
+
+	// ErrInjection 输入中包含试图改写系统指令的内容
+	ErrInjection = errors.New("输入包含可疑的指令内容")
+
+	// ErrSensitive 敏感处理器的匹配没有通过更严格的检查
+	ErrSensitive = errors.New("敏感操作未通过安全检查")
+)
+
+// injectionPatterns 内置的提示词注入特征
+var injectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(忽略|无视|忘记|忘掉|不要理会|跳过|覆盖).{0,12}(指令|指示|提示词?|规则|设定|要求|限制)`),
+	regexp.MustCompile(`(?i)(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|system)?\s*(instructions?|prompts?|rules)`),
+	regexp.MustCompile(`(你现在是|你现在扮演|从现在开始你是|从现在起你是|假装你是|请扮演)`),
+	regexp.MustCompile(`(?i)(system\s*prompt|系统提示词?|开发者模式|developer\s+mode|jailbreak|越狱)`),
+	regexp.MustCompile(`(输出|打印|告诉我|泄露|重复).{0,8}(提示词|系统提示|prompt)`),
+	regexp.MustCompile(`(?i)(^|\n)\s*(system|assistant|developer)\s*[:：]`),
+	regexp.MustCompile(`(?i)<\s*/?\s*user_input\s*>`),
+}
+
+// sensitivePatterns 对敏感处理器额外拒绝的内容（正常的家居指令不会包含）
+var sensitivePatterns = []*regexp.Regexp{
+	regexp.MustCompile("```"),
+	regexp.MustCompile(`(?i)https?://`),
+	regexp.MustCompile(`[{}<>]`),
+}
+
+// Check 检查用户输入的长度和注入特征，通过时返回nil
+func Check(cfg config.GuardConfig, input string) error {
+	if n := utf8.RuneCountInString(input); cfg.MaxInputLength > 0 && n > cfg.MaxInputLength {
+		return fmt.Errorf("%w（%d字，上限%d字）", ErrTooLong, n, cfg.MaxInputLength)
+	}
+
+	if p := matchAny(injectionPatterns, input); p != "" {
+		return fmt.Errorf("%w: %s", ErrInjection, p)
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			// 配置校验时已检查过，这里忽略
+			continue
+		}
+		if m := re.FindString(input); m != "" {
+			return fmt.Errorf("%w: %s", ErrInjection, m)
+		}
+	}
+	return nil
+}
+
+// CheckSensitive 对匹配到的敏感处理器（如门锁、安防）做更严格的检查：
+//   - 置信度不低于 sensitive_min_confidence
+//   - 不能是从多条指令中拆分出来的（敏感操作需要单独发送）
+//   - 输入较短，且不包含代码块、链接、标签等非口语内容
+//   - 输入中必须直接出现处理器的名称或关键词，不接受模型"推断"出来的匹配
+func CheckSensitive(cfg config.GuardConfig, input string, processor *model.Processor, confidence float64, split bool) error {
+	if confidence < cfg.SensitiveMinConfidence {
+		return fmt.Errorf("%w: 置信度%.2f低于%.2f", ErrSensitive, confidence, cfg.SensitiveMinConfidence)
+	}
+	if split {
+		return fmt.Errorf("%w: 请单独发送该指令", ErrSensitive)
+	}
+	if n := utf8.RuneCountInString(input); cfg.SensitiveMaxLength > 0 && n > cfg.SensitiveMaxLength {
+		return fmt.Errorf("%w: 指令过长（%d字，上限%d字）", ErrSensitive, n, cfg.SensitiveMaxLength)
+	}
+	if p := matchAny(sensitivePatterns, input); p != "" {
+		return fmt.Errorf("%w: 包含非法内容 %s", ErrSensitive, p)
+	}
+	if !mentions(input, processor) {
+		return fmt.Errorf("%w: 指令中没有提到%s", ErrSensitive, processor.Name)
+	}
+	return nil
+}
+
+// mentions 判断输入中是否直接提到了处理器的名称或关键词
+func mentions(input string, processor *model.Processor) bool {
+	lower := strings.ToLower(input)
+	if processor.Name != "" && strings.Contains(lower, strings.ToLower(processor.Name)) {
+		return true
+	}
+	for _, kw := range processor.Keywords {
+		if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny 返回第一个命中的内容，没有命中时返回空字符串
+func matchAny(patterns []*regexp.Regexp, input string) string {
+	for _, re := range patterns {
+		if loc := re.FindStringIndex(input); loc != nil {
+			return strings.TrimSpace(input[loc[0]:loc[1]])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Result 缓存的处理结果（响应状态码和响应体）
+type Result struct {
+	Status int
+	Body   interface{}
+}
+
+// entry 一个幂等键的处理状态
+type entry struct {
+	done    chan struct{}
+	ok      bool
+	result  Result
+	expires time.Time
+}
+
+// Cache 记录近期处理过的消息，重复的消息直接返回已有结果
+// 同一个键正在处理时，重复的请求等待处理完成后返回相同的结果，不会再次执行
+type Cache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// NewCache 创建缓存，处理完成的结果保留 ttl 时间
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Do 以 key 去重执行 fn
+// 返回的 duplicate 为 true 表示结果来自之前（或正在进行）的处理；
+// 等待正在进行的处理时 ctx 结束则返回 ctx 的错误
+func (c *Cache) Do(ctx context.Context, key string, fn func() Result) (result Result, duplicate bool, err error) {
+	c.mu.Lock()
+	c.cleanup()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-e.done:
+			if !e.ok {
+				// 之前的处理异常退出，没有结果，重新执行
+				return c.Do(ctx, key, fn)
+			}
+			return e.result, true, nil
+		case <-ctx.Done():
+			return Result{}, true, ctx.Err()
+		}
+	}
+
+	e := &entry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		if e.ok {
+			e.expires = time.Now().Add(c.ttl)
+		} else {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		close(e.done)
+	}()
+
+	e.result = fn()
+	e.ok = true
+	return e.result, false, nil
+}
+
+// cleanup 删除过期的结果（调用方需持有锁），正在处理的条目不会过期
+func (c *Cache) cleanup() {
+	now := time.Now()
+	for key, e := range c.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
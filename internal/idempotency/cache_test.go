@@ -0,0 +1,142 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []bool // 每次调用是否为重复
+		runs int32  // fn 的实际执行次数
+	}{
+		{"首次执行", []string{"a"}, []bool{false}, 1},
+		{"相同的键返回已有结果", []string{"a", "a", "a"}, []bool{false, true, true}, 1},
+		{"不同的键分别执行", []string{"a", "b", "a"}, []bool{false, false, true}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache(time.Minute)
+			var runs int32
+			for i, key := range tt.keys {
+				result, duplicate, err := c.Do(context.Background(), key, func() Result {
+					n := atomic.AddInt32(&runs, 1)
+					return Result{Status: 200, Body: n}
+				})
+				if err != nil {
+					t.Fatalf("Do(%s) 失败: %v", key, err)
+				}
+				if duplicate != tt.want[i] {
+					t.Errorf("第%d次 Do(%s) duplicate = %v，期望 %v", i+1, key, duplicate, tt.want[i])
+				}
+				if result.Status != 200 {
+					t.Errorf("第%d次 Do(%s) 状态码 = %d", i+1, key, result.Status)
+				}
+			}
+			if runs != tt.runs {
+				t.Errorf("执行次数 = %d，期望 %d", runs, tt.runs)
+			}
+		})
+	}
+}
+
+func TestDoWaitsForInFlight(t *testing.T) {
+	c := NewCache(time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go c.Do(context.Background(), "a", func() Result {
+		close(started)
+		<-release
+		return Result{Status: 200, Body: "first"}
+	})
+	<-started
+
+	// 处理中的重复请求等待首次处理完成，拿到相同的结果
+	var wg sync.WaitGroup
+	results := make([]Result, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, duplicate, err := c.Do(context.Background(), "a", func() Result {
+				t.Error("重复请求不应再次执行")
+				return Result{}
+			})
+			if err != nil || !duplicate {
+				t.Errorf("重复请求 duplicate = %v, err = %v", duplicate, err)
+			}
+			results[i] = result
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, r := range results {
+		if r.Body != "first" {
+			t.Errorf("第%d个重复请求的结果 = %v，期望首次处理的结果", i+1, r.Body)
+		}
+	}
+}
+
+func TestDoWaitCanceled(t *testing.T) {
+	c := NewCache(time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	go c.Do(context.Background(), "a", func() Result {
+		close(started)
+		<-release
+		return Result{}
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, duplicate, err := c.Do(ctx, "a", func() Result { return Result{} })
+	if err != context.DeadlineExceeded || !duplicate {
+		t.Errorf("等待超时: duplicate = %v, err = %v，期望 true, %v", duplicate, err, context.DeadlineExceeded)
+	}
+}
+
+func TestDoRetriesAfterPanic(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	func() {
+		defer func() { recover() }()
+		c.Do(context.Background(), "a", func() Result { panic("处理失败") })
+	}()
+
+	// 之前的处理异常退出，没有结果，重新执行
+	result, duplicate, err := c.Do(context.Background(), "a", func() Result { return Result{Status: 200} })
+	if err != nil || duplicate || result.Status != 200 {
+		t.Errorf("异常后重试: result = %v, duplicate = %v, err = %v", result, duplicate, err)
+	}
+}
+
+func TestDoExpires(t *testing.T) {
+	c := NewCache(10 * time.Millisecond)
+	var runs int32
+	fn := func() Result {
+		atomic.AddInt32(&runs, 1)
+		return Result{}
+	}
+
+	c.Do(context.Background(), "a", fn)
+	time.Sleep(20 * time.Millisecond)
+	if _, duplicate, _ := c.Do(context.Background(), "a", fn); duplicate {
+		t.Error("超过 ttl 后相同的键应重新处理")
+	}
+	if runs != 2 {
+		t.Errorf("执行次数 = %d，期望 2", runs)
+	}
+}
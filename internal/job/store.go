@@ -0,0 +1,136 @@
+package job
+
+import (
+	"sync"
+	"time"
+)
+
+// State 异步请求的状态
+type State string
+
+const (
+	// StatePending 已受理，尚未开始处理
+	StatePending State = "pending"
+
+	// StateRunning 正在处理（意图识别或等待后端响应）
+	StateRunning State = "running"
+
+	// StateSucceeded 执行成功
+	StateSucceeded State = "succeeded"
+
+	// StateQueued 后端暂时不可用，指令已写入本地队列，恢复后执行（不再跟踪执行结果）
+	StateQueued State = "queued"
+
+	// StateFailed 执行失败
+	StateFailed State = "failed"
+
+	// StateExpired 在超时时间内没有得到后端响应
+	StateExpired State = "expired"
+)
+
+// Job 异步请求记录
+type Job struct {
+	// TraceID 请求追踪ID
+	TraceID string `json:"trace_id"`
+
+	// State 当前状态
+	State State `json:"state"`
+
+	// Result 处理结果（与同步接口的响应体相同），完成后才有
+	Result interface{} `json:"result,omitempty"`
+
+	// CreatedAt 受理时间
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt 最后更新时间
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Done 是否已结束
+func (j *Job) Done() bool {
+	return j.State == StateSucceeded || j.State == StateFailed || j.State == StateQueued || j.State == StateExpired
+}
+
+// Store 异步请求记录的内存存储
+type Store struct {
+	timeout   time.Duration
+	retention time.Duration
+	mu        sync.Mutex
+	jobs      map[string]*Job
+}
+
+// NewStore 创建存储
+// timeout 为处理超时时间，超过后仍未结束的请求视为过期；retention 为结束后记录的保留时间
+func NewStore(timeout, retention time.Duration) *Store {
+	return &Store{
+		timeout:   timeout,
+		retention: retention,
+		jobs:      make(map[string]*Job),
+	}
+}
+
+// Create 登记新的异步请求
+func (s *Store) Create(traceID string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanup()
+
+	now := time.Now()
+	j := &Job{TraceID: traceID, State: StatePending, CreatedAt: now, UpdatedAt: now}
+	s.jobs[traceID] = j
+	copied := *j
+	return &copied
+}
+
+// Start 标记为处理中
+func (s *Store) Start(traceID string) {
+	s.update(traceID, StateRunning, nil)
+}
+
+// Finish 记录最终状态和结果
+func (s *Store) Finish(traceID string, state State, result interface{}) {
+	s.update(traceID, state, result)
+}
+
+// update 更新状态，已结束的请求不再改变
+func (s *Store) update(traceID string, state State, result interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[traceID]
+	if !ok || j.Done() {
+		return
+	}
+	j.State = state
+	if result != nil {
+		j.Result = result
+	}
+	j.UpdatedAt = time.Now()
+}
+
+// Get 查询请求记录，超时未结束的请求返回过期状态
+func (s *Store) Get(traceID string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[traceID]
+	if !ok {
+		return nil, false
+	}
+	if !j.Done() && s.timeout > 0 && time.Since(j.CreatedAt) > s.timeout {
+		j.State = StateExpired
+		j.UpdatedAt = time.Now()
+	}
+	copied := *j
+	return &copied, true
+}
+
+// cleanup 删除超过保留时间的记录（调用方需持有锁）
+func (s *Store) cleanup() {
+	for id, j := range s.jobs {
+		if s.retention > 0 && time.Since(j.UpdatedAt) > s.retention && (j.Done() || time.Since(j.CreatedAt) > s.timeout) {
+			delete(s.jobs, id)
+		}
+	}
+}
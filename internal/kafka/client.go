@@ -1,25 +1,47 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/idempotency"
 	"github.com/yoyo3287258/home-gateway/internal/model"
+	"github.com/yoyo3287258/home-gateway/internal/pending"
+)
+
+// ErrNotDelivered 请求没有发送到Kafka（broker不可用等），可以稍后重试
+var ErrNotDelivered = errors.New("请求未送达Kafka")
+
+// 请求消息头
+const (
+	// HeaderReplyTo 响应应发送到的topic
+	HeaderReplyTo = "reply-to"
+
+	// HeaderCorrelationID 关联ID（即TraceID），后端回复时应原样带回
+	HeaderCorrelationID = "correlation-id"
 )
 
 // Producer Kafka生产者
 type Producer struct {
-	producer sarama.SyncProducer
-	topic    string
+	producer    sarama.SyncProducer
+	topic       string
+	cloudEvents *config.CloudEventsConfig
 }
 
 // NewProducer 创建Kafka生产者
 func NewProducer(cfg *config.KafkaConfig) (*Producer, error) {
-	config := sarama.NewConfig()
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 3
 	config.Producer.Return.Successes = true
@@ -29,33 +51,76 @@ func NewProducer(cfg *config.KafkaConfig) (*Producer, error) {
 		return nil, fmt.Errorf("创建Kafka生产者失败: %w", err)
 	}
 
-	return &Producer{
+	p := &Producer{
 		producer: producer,
 		topic:    cfg.RequestTopic,
-	}, nil
+	}
+	if cfg.CloudEvents.Enabled {
+		p.cloudEvents = &cfg.CloudEvents
+	}
+	return p, nil
 }
 
 // SendRequest 发送请求消息到Kafka
 func (p *Producer) SendRequest(req *model.KafkaRequest) error {
+	return p.SendRequestWith(req, model.Dispatch{})
+}
+
+// SendRequestWith 按分发策略发送请求消息，策略中的topic为空时使用 request_topic
+func (p *Producer) SendRequestWith(req *model.KafkaRequest, policy model.Dispatch) error {
+	req.SchemaVersion = model.SchemaVersion
 	data, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("序列化请求失败: %w", err)
 	}
 
+	topic := policy.Topic
+	if topic == "" {
+		topic = p.topic
+	}
+
 	msg := &sarama.ProducerMessage{
-		Topic: p.topic,
-		Key:   sarama.StringEncoder(req.TraceID),
+		Topic: topic,
+		Key:   sarama.StringEncoder(partitionKey(req, policy.PartitionKey)),
 		Value: sarama.ByteEncoder(data),
 	}
+	msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(HeaderCorrelationID), Value: []byte(req.TraceID)})
+	if req.ReplyTo != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(HeaderReplyTo), Value: []byte(req.ReplyTo)})
+	}
+	if p.cloudEvents != nil {
+		msg.Headers = append(msg.Headers, cloudEventHeaders(p.cloudEvents, req)...)
+	}
 
 	_, _, err = p.producer.SendMessage(msg)
 	if err != nil {
-		return fmt.Errorf("发送Kafka消息失败: %w", err)
+		return fmt.Errorf("%w: 发送Kafka消息失败: %v", ErrNotDelivered, err)
 	}
 
 	return nil
 }
 
+// partitionKey 按策略计算消息的分区键，取不到对应的值时退回TraceID
+func partitionKey(req *model.KafkaRequest, strategy string) string {
+	var key string
+	switch {
+	case strategy == model.PartitionByProcessor:
+		key = req.ProcessorID
+	case strategy == model.PartitionByUser:
+		key = req.RawMessage.UserID
+	case strategy == model.PartitionByChat:
+		key = req.RawMessage.ChatID
+	case strings.HasPrefix(strategy, model.PartitionByParameterPrefix):
+		if v, ok := req.Parameters[strings.TrimPrefix(strategy, model.PartitionByParameterPrefix)]; ok {
+			key = fmt.Sprint(v)
+		}
+	}
+	if key == "" {
+		key = req.TraceID
+	}
+	return key
+}
+
 // Close 关闭生产者
 func (p *Producer) Close() error {
 	if p.producer != nil {
@@ -65,115 +130,181 @@ func (p *Producer) Close() error {
 }
 
 // Consumer Kafka消费者
+// 基于消费者组消费响应topic：分区由消费者组分配，新增分区会触发重平衡；
+// 已处理的消息提交位移，重平衡或重启后从上次提交的位置继续，期间产生的响应不会丢失
 type Consumer struct {
-	consumer      sarama.Consumer
-	topic         string
-	timeout       time.Duration
-	pendingMu     sync.RWMutex
-	pendingResps  map[string]chan *model.KafkaResponse
+	group   *groupConsumer
+	topic   string
+	timeout time.Duration
+	pending *pending.Registry
+
+	// seen 近期收到的CloudEvents响应事件，重复投递（如重平衡后重新消费）的事件不再交给等待方
+	seen *idempotency.Cache
+
+	// ready 首次分配到分区后关闭
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
+// seenEventTTL 响应和设备事件ID的保留时间，应长于最长的响应等待时间
+const seenEventTTL = 10 * time.Minute
+
 // NewConsumer 创建Kafka消费者
 func NewConsumer(cfg *config.KafkaConfig) (*Consumer, error) {
-	config := sarama.NewConfig()
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 	config.Consumer.Return.Errors = true
+	// 消费者组第一次启动时从最新位置开始，之后从已提交的位移继续
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	// 定期刷新元数据，及时发现新增的分区
+	config.Metadata.RefreshFrequency = time.Minute
+
+	// 使用独立响应topic时先确保topic存在
+	topic := cfg.ReplyTopicName()
+	if cfg.ReplyTopic != "" {
+		if err := ensureTopic(cfg, topic); err != nil {
+			fmt.Printf("⚠️  创建响应topic %s 失败（将依赖broker自动创建）: %v\n", topic, err)
+		}
+	}
 
-	consumer, err := sarama.NewConsumer(cfg.Brokers, config)
+	group, err := sarama.NewConsumerGroup(cfg.Brokers, cfg.ConsumerGroupName(), config)
 	if err != nil {
-		return nil, fmt.Errorf("创建Kafka消费者失败: %w", err)
+		return nil, fmt.Errorf("创建Kafka消费者组失败: %w", err)
 	}
 
 	c := &Consumer{
-		consumer:     consumer,
-		topic:        cfg.ResponseTopic,
-		timeout:      cfg.ResponseTimeout,
-		pendingResps: make(map[string]chan *model.KafkaResponse),
+		topic:   topic,
+		timeout: cfg.ResponseTimeout,
+		pending: pending.NewRegistry(),
+		seen:    idempotency.NewCache(seenEventTTL),
+		ready:   make(chan struct{}),
 	}
-
-	// 启动消费者协程
-	go c.consumeLoop()
+	c.group = startGroupConsumer(group, topic, "Kafka", c.handleMessage, c.onAssigned)
 
 	return c, nil
 }
 
-// consumeLoop 消费响应消息的循环
-func (c *Consumer) consumeLoop() {
-	partitions, err := c.consumer.Partitions(c.topic)
+// onAssigned 分配到分区后调用
+func (c *Consumer) onAssigned(session sarama.ConsumerGroupSession) {
+	fmt.Printf("Kafka消费者已分配分区: %v\n", session.Claims()[c.topic])
+	c.readyOnce.Do(func() { close(c.ready) })
+}
+
+// handleMessage 处理接收到的消息
+func (c *Consumer) handleMessage(msg *sarama.ConsumerMessage) {
+	resp, eventID, err := decodeResponse(msg)
 	if err != nil {
-		fmt.Printf("获取分区失败: %v\n", err)
+		fmt.Printf("解析响应消息失败 (分区: %d, 位移: %d): %v\n", msg.Partition, msg.Offset, err)
 		return
 	}
 
-	for _, partition := range partitions {
-		pc, err := c.consumer.ConsumePartition(c.topic, partition, sarama.OffsetNewest)
-		if err != nil {
-			fmt.Printf("订阅分区 %d 失败: %v\n", partition, err)
-			continue
+	if eventID != "" {
+		_, duplicate, _ := c.seen.Do(context.Background(), eventID, func() idempotency.Result { return idempotency.Result{} })
+		if duplicate {
+			fmt.Printf("[%s] 收到重复的响应事件 %s，已忽略\n", resp.TraceID, eventID)
+			return
 		}
+	}
 
-		go func(pc sarama.PartitionConsumer) {
-			for msg := range pc.Messages() {
-				c.handleMessage(msg)
-			}
-		}(pc)
+	if !c.pending.Deliver(resp) {
+		fmt.Printf("[%s] 收到无人等待的响应（可能已超时），已忽略\n", resp.TraceID)
 	}
 }
 
-// handleMessage 处理接收到的消息
-func (c *Consumer) handleMessage(msg *sarama.ConsumerMessage) {
-	var resp model.KafkaResponse
-	if err := json.Unmarshal(msg.Value, &resp); err != nil {
-		fmt.Printf("解析响应消息失败: %v\n", err)
-		return
+// ensureTopic 确保topic存在，不存在时创建单分区的topic
+func ensureTopic(cfg *config.KafkaConfig, topic string) error {
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Version == "" {
+		config.Version = sarama.V2_1_0_0
 	}
 
-	c.pendingMu.RLock()
-	ch, ok := c.pendingResps[resp.TraceID]
-	c.pendingMu.RUnlock()
+	admin, err := sarama.NewClusterAdmin(cfg.Brokers, config)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	topics, err := admin.ListTopics()
+	if err != nil {
+		return err
+	}
+	if _, ok := topics[topic]; ok {
+		return nil
+	}
 
-	if ok {
-		// 非阻塞发送，防止超时后channel被关闭
-		select {
-		case ch <- &resp:
-		default:
+	replication := cfg.TopicReplicationFactor
+	if replication <= 0 {
+		if replication, err = brokerReplicationFactor(admin); err != nil {
+			return fmt.Errorf("获取broker默认副本数失败（可设置 kafka.topic_replication_factor）: %w", err)
 		}
 	}
-}
 
-// WaitForResponse 等待指定TraceID的响应
-func (c *Consumer) WaitForResponse(traceID string) (*model.KafkaResponse, error) {
-	ch := make(chan *model.KafkaResponse, 1)
+	err = admin.CreateTopic(topic, &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: replication}, false)
+	if errors.Is(err, sarama.ErrTopicAlreadyExists) {
+		return nil
+	}
+	return err
+}
 
-	c.pendingMu.Lock()
-	c.pendingResps[traceID] = ch
-	c.pendingMu.Unlock()
+// brokerReplicationFactor 读取broker的 default.replication.factor 配置
+func brokerReplicationFactor(admin sarama.ClusterAdmin) (int16, error) {
+	_, controllerID, err := admin.DescribeCluster()
+	if err != nil {
+		return 0, err
+	}
 
-	defer func() {
-		c.pendingMu.Lock()
-		delete(c.pendingResps, traceID)
-		c.pendingMu.Unlock()
-	}()
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.BrokerResource,
+		Name:        strconv.Itoa(int(controllerID)),
+		ConfigNames: []string{"default.replication.factor"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if entry.Name == "default.replication.factor" {
+			n, err := strconv.ParseInt(entry.Value, 10, 16)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的 default.replication.factor: %q", entry.Value)
+			}
+			return int16(n), nil
+		}
+	}
+	return 0, fmt.Errorf("broker没有返回 default.replication.factor")
+}
 
+// WaitReady 等待消费者分配到分区，超时返回false
+// 分配完成前发送的请求，其响应可能落在尚未开始消费的位置之前
+func (c *Consumer) WaitReady(timeout time.Duration) bool {
 	select {
-	case resp := <-ch:
-		return resp, nil
-	case <-time.After(c.timeout):
-		return nil, fmt.Errorf("等待响应超时（%v）", c.timeout)
+	case <-c.ready:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
 
+// ProgressFunc 收到中间消息（进度、部分结果）时的回调
+type ProgressFunc = pending.ProgressFunc
+
 // Close 关闭消费者
 func (c *Consumer) Close() error {
-	if c.consumer != nil {
-		return c.consumer.Close()
-	}
-	return nil
+	return c.group.Close()
 }
 
 // Client Kafka客户端（封装生产者和消费者）
 type Client struct {
 	Producer *Producer
 	Consumer *Consumer
+
+	// replyTo 请求中携带的响应topic，为空时后端回复到默认的响应topic
+	replyTo string
 }
 
 // NewClient 创建Kafka客户端
@@ -189,21 +320,51 @@ func NewClient(cfg *config.KafkaConfig) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{
+	client := &Client{
 		Producer: producer,
 		Consumer: consumer,
-	}, nil
+	}
+	if cfg.ReplyTopic != "" {
+		client.replyTo = cfg.ReplyTopicName()
+	}
+	return client, nil
 }
 
-// SendAndWait 发送请求并等待响应
-func (c *Client) SendAndWait(req *model.KafkaRequest) (*model.KafkaResponse, error) {
+// Dispatch 按处理器的分发策略发送请求
+// 超时时间依次取策略中的 response_timeout、参数 timeout、全局 response_timeout；
+// 策略不等待响应时发送成功即返回，响应为nil；progress 不为nil时接收中间消息；ctx 结束时停止等待
+// 等待响应时先登记再发送，避免后端响应过快时在登记之前到达
+func (c *Client) Dispatch(ctx context.Context, req *model.KafkaRequest, policy model.Dispatch, timeout time.Duration, progress ProgressFunc) (*model.KafkaResponse, error) {
+	if !policy.WantsResponse() {
+		// 不等待响应，也不要求后端回复
+		req.ReplyTo = ""
+		return nil, c.Producer.SendRequestWith(req, policy)
+	}
+
+	if policy.ResponseTimeout > 0 {
+		timeout = policy.ResponseTimeout
+	}
+	if timeout <= 0 {
+		timeout = c.Consumer.timeout
+	}
+	if !c.Consumer.WaitReady(c.Consumer.timeout) {
+		return nil, fmt.Errorf("%w: Kafka消费者尚未分配到分区", ErrNotDelivered)
+	}
+
+	waiter := c.Consumer.pending.Register(req.TraceID)
+	defer c.Consumer.pending.Unregister(req.TraceID)
+
+	if req.ReplyTo == "" {
+		req.ReplyTo = c.replyTo
+	}
+
 	// 发送请求
-	if err := c.Producer.SendRequest(req); err != nil {
+	if err := c.Producer.SendRequestWith(req, policy); err != nil {
 		return nil, err
 	}
 
 	// 等待响应
-	return c.Consumer.WaitForResponse(req.TraceID)
+	return pending.Wait(ctx, waiter, timeout, progress)
 }
 
 // Close 关闭客户端
@@ -0,0 +1,148 @@
+package kafka
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// CloudEvents Kafka协议绑定（binary模式）使用的消息头
+const (
+	HeaderCEID            = "ce_id"
+	HeaderCESource        = "ce_source"
+	HeaderCEType          = "ce_type"
+	HeaderCESpecVersion   = "ce_specversion"
+	HeaderCETime          = "ce_time"
+	HeaderCESubject       = "ce_subject"
+	HeaderCEDataSchema    = "ce_dataschema"
+	HeaderCESchemaVersion = "ce_schemaversion"
+	HeaderCETraceParent   = "ce_traceparent"
+	HeaderContentType     = "content-type"
+
+	// HeaderTraceParent W3C Trace Context消息头，供不解析CloudEvents的追踪工具使用
+	HeaderTraceParent = "traceparent"
+)
+
+// CloudEvents事件类型
+const (
+	EventTypeRequest  = "com.home-gateway.command.request"
+	EventTypeResponse = "com.home-gateway.command.response"
+)
+
+// cloudEventHeaders 生成请求的CloudEvents消息头（binary模式，消息体仍为请求JSON）
+func cloudEventHeaders(cfg *config.CloudEventsConfig, req *model.KafkaRequest) []sarama.RecordHeader {
+	traceParent := traceParentOf(req)
+	headers := [][2]string{
+		{HeaderCEID, req.TraceID},
+		{HeaderCESource, cfg.Source},
+		{HeaderCEType, EventTypeRequest},
+		{HeaderCESpecVersion, "1.0"},
+		{HeaderCETime, req.CreatedAt.UTC().Format(time.RFC3339Nano)},
+		{HeaderCESubject, req.ProcessorID},
+		{HeaderCEDataSchema, strings.TrimSuffix(cfg.SchemaBaseURL, "/") + "/request-v" + model.SchemaVersion + ".json"},
+		{HeaderCESchemaVersion, model.SchemaVersion},
+		{HeaderCETraceParent, traceParent},
+		{HeaderTraceParent, traceParent},
+		{HeaderContentType, "application/json"},
+	}
+
+	result := make([]sarama.RecordHeader, 0, len(headers))
+	for _, h := range headers {
+		result = append(result, sarama.RecordHeader{Key: []byte(h[0]), Value: []byte(h[1])})
+	}
+	return result
+}
+
+// traceParentOf 生成W3C traceparent，子指令与父指令使用相同的trace-id
+func traceParentOf(req *model.KafkaRequest) string {
+	id := req.ParentTraceID
+	if id == "" {
+		id = req.TraceID
+	}
+
+	// UUID格式的TraceID去掉连字符即为32位十六进制；其他格式取哈希
+	traceID := strings.ReplaceAll(id, "-", "")
+	if _, err := hex.DecodeString(traceID); err != nil || len(traceID) != 32 {
+		sum := sha256.Sum256([]byte(id))
+		traceID = hex.EncodeToString(sum[:16])
+	}
+
+	span := make([]byte, 8)
+	rand.Read(span)
+	return fmt.Sprintf("00-%s-%s-01", strings.ToLower(traceID), hex.EncodeToString(span))
+}
+
+// unwrapMessage 取出消息的数据部分和消息头，同时接受三种格式：
+// 旧格式（消息体为JSON）、CloudEvents binary模式（ce_* 消息头 + JSON消息体）、
+// CloudEvents structured模式（content-type 为 application/cloudevents+json，数据在 data 中）
+func unwrapMessage(msg *sarama.ConsumerMessage) ([]byte, map[string]string, error) {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[strings.ToLower(string(h.Key))] = string(h.Value)
+	}
+
+	if !strings.HasPrefix(headers[HeaderContentType], "application/cloudevents+json") {
+		return msg.Value, headers, nil
+	}
+
+	var event struct {
+		ID            string          `json:"id"`
+		Source        string          `json:"source"`
+		SchemaVersion string          `json:"schemaversion"`
+		Data          json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, nil, fmt.Errorf("解析CloudEvents事件失败: %w", err)
+	}
+	// structured模式的属性与binary模式的消息头同等对待
+	if event.ID != "" {
+		headers[HeaderCEID] = event.ID
+	}
+	if event.Source != "" {
+		headers[HeaderCESource] = event.Source
+	}
+	if event.SchemaVersion != "" {
+		headers[HeaderCESchemaVersion] = event.SchemaVersion
+	}
+	return event.Data, headers, nil
+}
+
+// decodeResponse 解析响应消息（旧格式或CloudEvents格式）
+// eventID 为CloudEvents事件的唯一标识（source + id），用于丢弃重复投递的事件；旧格式消息为空
+func decodeResponse(msg *sarama.ConsumerMessage) (resp *model.KafkaResponse, eventID string, err error) {
+	data, headers, err := unwrapMessage(msg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp = &model.KafkaResponse{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return nil, "", err
+	}
+
+	if resp.SchemaVersion == "" {
+		resp.SchemaVersion = headers[HeaderCESchemaVersion]
+	}
+	if resp.SchemaVersion != "" && resp.SchemaVersion != model.SchemaVersion {
+		fmt.Printf("[%s] 响应的消息格式版本 %s 与网关支持的版本 %s 不一致，按兼容方式解析\n",
+			resp.TraceID, resp.SchemaVersion, model.SchemaVersion)
+	}
+
+	// 后端没有在消息体中带回TraceID时，使用关联ID消息头
+	if resp.TraceID == "" {
+		resp.TraceID = headers[HeaderCorrelationID]
+	}
+
+	if id := headers[HeaderCEID]; id != "" {
+		eventID = headers[HeaderCESource] + "|" + id
+	}
+	return resp, eventID, nil
+}
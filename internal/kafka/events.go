@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/idempotency"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// EventConsumer 消费后端主动上报的设备事件
+// 所有网关实例共用一个消费者组，每个事件只会被一个实例处理
+type EventConsumer struct {
+	group   *groupConsumer
+	handler func(event *model.DeviceEvent)
+
+	// seen 近期处理过的事件（source + id），重复投递（如重平衡、重启后重新消费）的事件不再推送
+	seen *idempotency.Cache
+}
+
+// NewEventConsumer 创建事件消费者，收到的事件交给 handler 处理
+func NewEventConsumer(cfg *config.KafkaConfig, events *config.EventsConfig, handler func(event *model.DeviceEvent)) (*EventConsumer, error) {
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+
+	group, err := sarama.NewConsumerGroup(cfg.Brokers, events.ConsumerGroup, config)
+	if err != nil {
+		return nil, fmt.Errorf("创建事件消费者组失败: %w", err)
+	}
+
+	c := &EventConsumer{handler: handler, seen: idempotency.NewCache(seenEventTTL)}
+	c.group = startGroupConsumer(group, events.Topic, "事件", c.handleMessage, nil)
+
+	return c, nil
+}
+
+// handleMessage 解析事件并交给处理函数
+func (c *EventConsumer) handleMessage(msg *sarama.ConsumerMessage) {
+	data, headers, err := unwrapMessage(msg)
+	if err != nil {
+		fmt.Printf("解析事件消息失败 (分区: %d, 位移: %d): %v\n", msg.Partition, msg.Offset, err)
+		return
+	}
+
+	var event model.DeviceEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		fmt.Printf("解析事件消息失败 (分区: %d, 位移: %d): %v\n", msg.Partition, msg.Offset, err)
+		return
+	}
+	if event.ID == "" {
+		event.ID = headers[HeaderCEID]
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = msg.Timestamp
+	}
+
+	if event.ID != "" {
+		eventID := headers[HeaderCESource] + "|" + event.ID
+		_, duplicate, _ := c.seen.Do(context.Background(), eventID, func() idempotency.Result { return idempotency.Result{} })
+		if duplicate {
+			fmt.Printf("[事件 %s] 收到重复的设备事件，已忽略\n", event.ID)
+			return
+		}
+	}
+
+	c.handler(&event)
+}
+
+// Close 关闭事件消费者
+func (c *EventConsumer) Close() error {
+	return c.group.Close()
+}
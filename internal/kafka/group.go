@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// groupConsumer 消费者组的公共消费循环，响应消费者和事件消费者共用
+// 循环消费单个topic，出错时按指数退避重启，每条消息交给 handle 处理后提交位移
+type groupConsumer struct {
+	group sarama.ConsumerGroup
+	topic string
+
+	// name 日志中的消费者名称
+	name string
+
+	// handle 处理单条消息
+	handle func(msg *sarama.ConsumerMessage)
+
+	// onSetup 分配到分区后调用（可选）
+	onSetup func(session sarama.ConsumerGroupSession)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startGroupConsumer 开始消费topic，消费者组的错误输出到控制台
+func startGroupConsumer(group sarama.ConsumerGroup, topic, name string, handle func(msg *sarama.ConsumerMessage), onSetup func(session sarama.ConsumerGroupSession)) *groupConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &groupConsumer{
+		group:   group,
+		topic:   topic,
+		name:    name,
+		handle:  handle,
+		onSetup: onSetup,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go g.consumeLoop(ctx)
+	go g.logErrors()
+
+	return g
+}
+
+// consumeLoop 消费消息的循环
+// Consume 在每次重平衡后返回，需要循环调用；出错时按指数退避重启
+func (g *groupConsumer) consumeLoop(ctx context.Context) {
+	defer close(g.done)
+
+	backoff := time.Second
+	for {
+		err := g.group.Consume(ctx, []string{g.topic}, g)
+		if ctx.Err() != nil || errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+
+		fmt.Printf("%s消费失败，%v后重试: %v\n", g.name, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// logErrors 输出消费者组的错误
+func (g *groupConsumer) logErrors() {
+	for err := range g.group.Errors() {
+		fmt.Printf("%s消费者错误: %v\n", g.name, err)
+	}
+}
+
+// Setup 分配到分区后调用
+func (g *groupConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	if g.onSetup != nil {
+		g.onSetup(session)
+	}
+	return nil
+}
+
+// Cleanup 重平衡或退出前调用
+func (g *groupConsumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim 消费单个分区
+func (g *groupConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			g.handle(msg)
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Close 停止消费并关闭消费者组
+func (g *groupConsumer) Close() error {
+	g.cancel()
+	err := g.group.Close()
+	<-g.done
+	return err
+}
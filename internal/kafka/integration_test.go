@@ -0,0 +1,193 @@
+//go:build integration
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/yoyo3287258/home-gateway/internal/config"
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// 集成测试连接本地运行的 broker（TLS + SASL/SCRAM），通过环境变量配置：
+//
+//	KAFKA_TEST_BROKERS    broker地址，多个用逗号分隔（未设置时跳过）
+//	KAFKA_TEST_CA_FILE    broker证书的CA（PEM）
+//	KAFKA_TEST_MECHANISM  SCRAM-SHA-256 或 SCRAM-SHA-512（默认 SCRAM-SHA-512）
+//	KAFKA_TEST_USERNAME   用户名
+//	KAFKA_TEST_PASSWORD   密码
+//	KAFKA_TEST_VERSION    固定的协议版本（默认 2.8.0）
+//
+// 运行: go test -tags integration ./internal/kafka/
+
+// integrationConfig 按环境变量生成测试配置，每次使用新的topic
+func integrationConfig(t *testing.T) *config.KafkaConfig {
+	t.Helper()
+
+	brokers := os.Getenv("KAFKA_TEST_BROKERS")
+	if brokers == "" {
+		t.Skip("未设置 KAFKA_TEST_BROKERS，跳过Kafka集成测试")
+	}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	cfg := &config.KafkaConfig{
+		Brokers:         strings.Split(brokers, ","),
+		RequestTopic:    "it.request." + suffix,
+		ResponseTopic:   "it.response." + suffix,
+		ConsumerGroup:   "it-gateway",
+		InstanceID:      suffix,
+		ResponseTimeout: 30 * time.Second,
+		ClientID:        "home-gateway-it",
+		Version:         envOr("KAFKA_TEST_VERSION", "2.8.0"),
+		TLS: config.KafkaTLSConfig{
+			Enabled: true,
+			CAFile:  os.Getenv("KAFKA_TEST_CA_FILE"),
+		},
+		SASL: config.KafkaSASLConfig{
+			Mechanism: envOr("KAFKA_TEST_MECHANISM", sarama.SASLTypeSCRAMSHA512),
+			Username:  os.Getenv("KAFKA_TEST_USERNAME"),
+			Password:  os.Getenv("KAFKA_TEST_PASSWORD"),
+		},
+	}
+
+	for _, topic := range []string{cfg.RequestTopic, cfg.ResponseTopic} {
+		if err := ensureTopic(cfg, topic); err != nil {
+			t.Fatalf("创建topic %s 失败: %v", topic, err)
+		}
+	}
+	return cfg
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func TestIntegrationSaramaConfig(t *testing.T) {
+	cfg := integrationConfig(t)
+
+	sc, err := newSaramaConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.ClientID != cfg.ClientID {
+		t.Errorf("ClientID = %q, want %q", sc.ClientID, cfg.ClientID)
+	}
+	if want, _ := sarama.ParseKafkaVersion(cfg.Version); sc.Version != want {
+		t.Errorf("Version = %v, want %v", sc.Version, want)
+	}
+	if !sc.Net.TLS.Enable || !sc.Net.SASL.Enable {
+		t.Errorf("TLS/SASL 未启用: tls=%v sasl=%v", sc.Net.TLS.Enable, sc.Net.SASL.Enable)
+	}
+
+	// 固定版本后协议协商应成功
+	client, err := sarama.NewClient(cfg.Brokers, sc)
+	if err != nil {
+		t.Fatalf("连接broker失败: %v", err)
+	}
+	defer client.Close()
+	if len(client.Brokers()) == 0 {
+		t.Error("没有获取到broker元数据")
+	}
+}
+
+func TestIntegrationRequestResponse(t *testing.T) {
+	cfg := integrationConfig(t)
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("创建Kafka客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	go echoBackend(t, cfg)
+
+	req := &model.KafkaRequest{
+		TraceID:     "it-" + cfg.InstanceID,
+		ProcessorID: "light_generic",
+		Parameters:  map[string]interface{}{"room": "客厅", "action": "on"},
+		CreatedAt:   time.Now(),
+	}
+	resp, err := client.Dispatch(context.Background(), req, model.Dispatch{}, cfg.ResponseTimeout, nil)
+	if err != nil {
+		t.Fatalf("等待响应失败: %v", err)
+	}
+	if !resp.Success || resp.TraceID != req.TraceID {
+		t.Errorf("响应 = %+v，期望成功且 trace_id 为 %s", resp, req.TraceID)
+	}
+}
+
+func TestIntegrationWrongPassword(t *testing.T) {
+	cfg := integrationConfig(t)
+	cfg.SASL.Password += "-wrong"
+
+	if p, err := NewProducer(cfg); err == nil {
+		p.Close()
+		t.Fatal("错误的密码应认证失败")
+	}
+}
+
+// echoBackend 模拟后端：读取一条请求并回复成功
+func echoBackend(t *testing.T, cfg *config.KafkaConfig) {
+	sc, err := newSaramaConfig(cfg)
+	if err != nil {
+		t.Errorf("创建后端配置失败: %v", err)
+		return
+	}
+	sc.Producer.Return.Successes = true
+
+	consumer, err := sarama.NewConsumer(cfg.Brokers, sc)
+	if err != nil {
+		t.Errorf("创建后端消费者失败: %v", err)
+		return
+	}
+	defer consumer.Close()
+
+	pc, err := consumer.ConsumePartition(cfg.RequestTopic, 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Errorf("消费请求topic失败: %v", err)
+		return
+	}
+	defer pc.Close()
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, sc)
+	if err != nil {
+		t.Errorf("创建后端生产者失败: %v", err)
+		return
+	}
+	defer producer.Close()
+
+	select {
+	case msg := <-pc.Messages():
+		var req model.KafkaRequest
+		if err := json.Unmarshal(msg.Value, &req); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+			return
+		}
+		topic := req.ReplyTo
+		if topic == "" {
+			topic = cfg.ResponseTopic
+		}
+		data, _ := json.Marshal(&model.KafkaResponse{
+			TraceID:     req.TraceID,
+			ProcessorID: req.ProcessorID,
+			Success:     true,
+			Result:      "ok",
+			ProcessedAt: time.Now(),
+		})
+		if _, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(data)}); err != nil {
+			t.Errorf("发送响应失败: %v", err)
+		}
+	case <-time.After(cfg.ResponseTimeout):
+		t.Error("后端没有收到请求")
+	}
+}
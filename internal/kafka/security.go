@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+	"github.com/yoyo3287258/home-gateway/internal/config"
+)
+
+// newSaramaConfig 创建生产者、消费者和管理客户端共用的基础配置（客户端ID、版本、TLS、SASL）
+func newSaramaConfig(cfg *config.KafkaConfig) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+
+	if cfg.ClientID != "" {
+		config.ClientID = cfg.ClientID
+	}
+	if cfg.Version != "" {
+		version, err := sarama.ParseKafkaVersion(cfg.Version)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.version 无效: %w", err)
+		}
+		config.Version = version
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if cfg.SASL.Mechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = cfg.SASL.Username
+		config.Net.SASL.Password = cfg.SASL.Password
+		config.Net.SASL.Handshake = true
+
+		switch strings.ToUpper(cfg.SASL.Mechanism) {
+		case sarama.SASLTypePlaintext:
+			config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case sarama.SASLTypeSCRAMSHA256:
+			config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: scram.SHA256}
+			}
+		case sarama.SASLTypeSCRAMSHA512:
+			config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: scram.SHA512}
+			}
+		default:
+			return nil, fmt.Errorf("kafka.sasl.mechanism 无效: %s（可选: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512）", cfg.SASL.Mechanism)
+		}
+	}
+
+	return config, nil
+}
+
+// newTLSConfig 根据CA证书、客户端证书创建TLS配置
+func newTLSConfig(cfg *config.KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取Kafka CA证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("Kafka CA证书 %s 中没有有效的PEM证书", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// 客户端证书（双向TLS）
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载Kafka客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// scramClient 基于 xdg-go/scram 实现 sarama.SCRAMClient
+type scramClient struct {
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+// Begin 开始SCRAM认证
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.ClientConversation = client.NewConversation()
+	return nil
+}
+
+// Step 处理服务端的挑战
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+// Done 认证是否完成
+func (c *scramClient) Done() bool {
+	return c.ClientConversation.Done()
+}
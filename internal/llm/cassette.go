@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 录制/回放模式
+const (
+	// CassetteRecord 录制模式：正常调用LLM，并把每一对请求/响应追加写入录制文件
+	CassetteRecord = "record"
+
+	// CassetteReplay 回放模式：只从录制文件返回响应，不访问网络
+	CassetteReplay = "replay"
+)
+
+// ErrCassetteMiss 回放模式下录制文件中没有对应的请求
+var ErrCassetteMiss = errors.New("LLM录制文件中没有匹配的请求")
+
+// cassetteEntry 录制文件中的一条记录（JSONL格式，每行一条）
+type cassetteEntry struct {
+	Key        string        `json:"key"`
+	RecordedAt time.Time     `json:"recorded_at"`
+	Request    ChatRequest   `json:"request"`
+	Response   *ChatResponse `json:"response"`
+}
+
+// cassetteTransport 录制/回放LLM请求的Transport
+type cassetteTransport struct {
+	mode    string
+	path    string
+	next    Transport
+	mu      sync.Mutex
+	entries map[string]*ChatResponse
+}
+
+// newCassetteTransport 创建录制/回放Transport
+// 返回的Transport总是可用的：回放模式下加载失败时所有请求都会未命中，而不会退回到真实调用
+func newCassetteTransport(mode, path string, next Transport) (*cassetteTransport, error) {
+	t := &cassetteTransport{
+		mode:    mode,
+		path:    path,
+		next:    next,
+		entries: make(map[string]*ChatResponse),
+	}
+
+	if path == "" {
+		return t, fmt.Errorf("未配置录制文件路径 (llm.cassette.path)")
+	}
+
+	if err := t.load(); err != nil {
+		if mode == CassetteRecord && os.IsNotExist(err) {
+			return t, nil
+		}
+		return t, err
+	}
+
+	return t, nil
+}
+
+// load 读取录制文件，同一请求出现多次时以最后一次为准
+func (t *cassetteTransport) load() error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry cassetteEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("解析录制文件第%d行失败: %w", line, err)
+		}
+		t.entries[entry.Key] = entry.Response
+	}
+	return scanner.Err()
+}
+
+// RoundTrip 录制模式下调用下一层并记录，回放模式下直接返回录制的响应
+func (t *cassetteTransport) RoundTrip(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	key := cassetteKey(req)
+
+	if t.mode == CassetteReplay {
+		t.mu.Lock()
+		resp, ok := t.entries[key]
+		t.mu.Unlock()
+		if !ok {
+			last := ""
+			if n := len(req.Messages); n > 0 {
+				last = req.Messages[n-1].Content
+			}
+			return nil, fmt.Errorf("%w（key: %s，文件: %s，最后一条消息: %s）", ErrCassetteMiss, key, t.path, last)
+		}
+		return resp, nil
+	}
+
+	resp, err := t.next.RoundTrip(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error == nil {
+		if err := t.record(key, req, resp); err != nil {
+			fmt.Printf("⚠️  写入LLM录制文件失败: %v\n", err)
+		}
+	}
+	return resp, nil
+}
+
+// record 追加写入一条记录
+func (t *cassetteTransport) record(key string, req ChatRequest, resp *ChatResponse) error {
+	data, err := json.Marshal(cassetteEntry{
+		Key:        key,
+		RecordedAt: time.Now(),
+		Request:    req,
+		Response:   resp,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries[key] = resp
+
+	if dir := filepath.Dir(t.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// cassetteKey 计算请求的哈希，作为录制文件中的键
+func cassetteKey(req ChatRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
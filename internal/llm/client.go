@@ -1,13 +1,14 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"reflect"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/yoyo3287258/home-gateway/internal/config"
@@ -15,24 +16,61 @@ import (
 
 // Client LLM API客户端
 type Client struct {
-	baseURL    string
-	apiKey     string
 	model      string
-	httpClient *http.Client
+	transport  Transport
 	maxRetries int
+
+	prompts          *template.Template
+	maxMatchExamples int
 }
 
 // NewClient 创建LLM客户端
-func NewClient(cfg *config.LLMConfig) *Client {
-	return &Client{
+// 回放模式下录制文件加载失败时返回错误：没有录制内容时每次调用都会失败
+func NewClient(cfg *config.LLMConfig) (*Client, error) {
+	prompts, err := loadPrompts(cfg.PromptsDir)
+	if err != nil {
+		fmt.Printf("⚠️  加载提示词模板失败，使用内置模板: %v\n", err)
+		prompts, _ = loadPrompts("")
+	}
+
+	var transport Transport = &httpTransport{
 		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
 		apiKey:  cfg.APIKey,
-		model:   cfg.Model,
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout,
 		},
-		maxRetries: cfg.MaxRetries,
 	}
+	if cfg.Provider == ProviderMock {
+		transport = &mockTransport{}
+	}
+
+	// 录制/回放模式
+	switch cfg.Cassette.Mode {
+	case CassetteRecord, CassetteReplay:
+		cassette, err := newCassetteTransport(cfg.Cassette.Mode, cfg.Cassette.Path, transport)
+		if err != nil {
+			if cfg.Cassette.Mode == CassetteReplay {
+				return nil, fmt.Errorf("加载LLM录制文件失败: %w", err)
+			}
+			fmt.Printf("⚠️  加载LLM录制文件失败: %v\n", err)
+		}
+		transport = cassette
+	}
+
+	return &Client{
+		model:            cfg.Model,
+		transport:        transport,
+		maxRetries:       cfg.MaxRetries,
+		prompts:          prompts,
+		maxMatchExamples: cfg.MaxMatchExamples,
+	}, nil
+}
+
+// UsesCassette 是否处于录制/回放模式
+// 录制文件按完整请求的哈希匹配，此时提示词中不应包含每次运行都不同的内容（如当前时间）
+func (c *Client) UsesCassette() bool {
+	_, ok := c.transport.(*cassetteTransport)
+	return ok
 }
 
 // ChatMessage 对话消息
@@ -94,41 +132,21 @@ func (c *Client) Chat(ctx context.Context, messages []ChatMessage) (string, erro
 		if err == nil {
 			return result, nil
 		}
+		if errors.Is(err, ErrCassetteMiss) {
+			// 回放未命中重试也不会成功
+			return "", err
+		}
 		lastErr = err
 	}
 
 	return "", fmt.Errorf("LLM请求失败（已重试%d次）: %w", c.maxRetries, lastErr)
 }
 
-// doRequest 执行HTTP请求
+// doRequest 执行一次对话请求
 func (c *Client) doRequest(ctx context.Context, req ChatRequest) (string, error) {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
+	chatResp, err := c.transport.RoundTrip(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	var chatResp ChatResponse
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w, 原始响应: %s", err, string(respBody))
+		return "", err
 	}
 
 	if chatResp.Error != nil {
@@ -140,50 +158,84 @@ func (c *Client) doRequest(ctx context.Context, req ChatRequest) (string, error)
 		return "", fmt.Errorf("LLM返回空响应")
 	}
 
+	if usage := usageFrom(ctx); usage != nil {
+		usage.add(chatResp)
+	}
+
 	return chatResp.Choices[0].Message.Content, nil
 }
 
+// ErrTruncated LLM的输出被截断，结果由网关补全，可能缺少字段，不能直接用于执行指令
+var ErrTruncated = errors.New("LLM输出被截断")
+
 // ChatWithJSON 发送对话请求并解析JSON响应
+// 响应无法解析或被截断时，把问题反馈给LLM进行一次修复；
+// 修复后仍只有补全的结果时，result 为补全的结果并返回 ErrTruncated
 func (c *Client) ChatWithJSON(ctx context.Context, messages []ChatMessage, result interface{}) error {
 	content, err := c.Chat(ctx, messages)
 	if err != nil {
 		return err
 	}
 
-	// 尝试提取JSON（LLM可能会在JSON前后添加额外文本）
-	jsonStr := extractJSON(content)
-	if jsonStr == "" {
-		return fmt.Errorf("LLM响应中未找到有效JSON: %s", content)
+	truncated, parseErr := parseJSONResponse(content, result)
+	if parseErr == nil && !truncated {
+		return nil
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), result); err != nil {
-		return fmt.Errorf("解析LLM JSON响应失败: %w, 内容: %s", err, jsonStr)
+	// 修复轮：带上原始回复和问题，要求LLM只返回完整的JSON
+	problem := "你上一次的回复不完整（被截断）。"
+	if parseErr != nil {
+		problem = fmt.Sprintf("你上一次的回复无法解析为JSON（%v）。", parseErr)
 	}
+	repairMessages := append(append([]ChatMessage(nil), messages...),
+		ChatMessage{Role: "assistant", Content: content},
+		ChatMessage{Role: "user", Content: problem + "请按要求的格式重新输出完整的JSON，只返回JSON，不要有其他内容。"},
+	)
 
-	return nil
-}
+	repaired, err := c.Chat(ctx, repairMessages)
+	if err != nil {
+		if parseErr == nil {
+			return fmt.Errorf("%w（修复请求失败: %v）", ErrTruncated, err)
+		}
+		return fmt.Errorf("%w（修复请求失败: %v）", parseErr, err)
+	}
 
-// extractJSON 从文本中提取JSON
-func extractJSON(s string) string {
-	// 尝试找到JSON对象
-	start := strings.Index(s, "{")
-	if start == -1 {
-		// 尝试找JSON数组
-		start = strings.Index(s, "[")
-		if start == -1 {
-			return ""
+	if parseErr == nil {
+		// 第一次的结果已经补全写入 result，修复结果不完整时保留它
+		var retry json.RawMessage
+		if again, err := parseJSONResponse(repaired, &retry); err != nil || again {
+			return ErrTruncated
 		}
-		end := strings.LastIndex(s, "]")
-		if end == -1 || end <= start {
-			return ""
+		// 先清空补全的结果，避免残留的字段（map会合并）
+		v := reflect.ValueOf(result).Elem()
+		v.Set(reflect.Zero(v.Type()))
+		if err := json.Unmarshal(retry, result); err != nil {
+			return fmt.Errorf("%w（修复后仍无法解析: %v）", ErrTruncated, err)
 		}
-		return s[start : end+1]
+		return nil
 	}
 
-	end := strings.LastIndex(s, "}")
-	if end == -1 || end <= start {
-		return ""
+	truncated, err = parseJSONResponse(repaired, result)
+	if err != nil {
+		return fmt.Errorf("%w（修复后仍无法解析: %v）", parseErr, err)
+	}
+	if truncated {
+		return ErrTruncated
+	}
+	return nil
+}
+
+// parseJSONResponse 从LLM响应中提取并解析JSON，truncated 表示JSON被截断、由网关补全
+func parseJSONResponse(content string, result interface{}) (truncated bool, err error) {
+	// 尝试提取JSON（LLM可能会在JSON前后添加额外文本或使用代码块）
+	jsonStr, truncated := extractJSON(content)
+	if jsonStr == "" {
+		return false, fmt.Errorf("LLM响应中未找到有效JSON: %s", content)
+	}
+
+	if err := json.Unmarshal([]byte(jsonStr), result); err != nil {
+		return false, fmt.Errorf("解析LLM JSON响应失败: %w, 内容: %s", err, jsonStr)
 	}
 
-	return s[start : end+1]
+	return truncated, nil
 }
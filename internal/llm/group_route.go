@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// groupView 提示词中的分组信息
+type groupView struct {
+	model.Group
+
+	// Processors 分组内已启用处理器的名称
+	Processors []string
+}
+
+// MatchGroups 使用LLM判断用户输入属于哪个处理器分组
+// 只考虑包含已启用处理器的分组
+func (c *Client) MatchGroups(ctx context.Context, userInput string, groups []model.Group, processors []model.Processor) (*model.GroupMatchResult, error) {
+	names := make(map[string][]string)
+	for _, p := range processors {
+		if p.Enabled {
+			names[p.Group] = append(names[p.Group], p.Name)
+		}
+	}
+
+	var views []groupView
+	var candidates []model.Group
+	for _, g := range groups {
+		if len(names[g.ID]) == 0 {
+			continue
+		}
+		views = append(views, groupView{Group: g, Processors: names[g.ID]})
+		candidates = append(candidates, g)
+	}
+
+	systemPrompt, err := c.renderPrompt(promptGroup, struct {
+		Groups []groupView
+	}{Groups: views})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []ChatMessage{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, groupExampleTurns(processors, c.maxMatchExamples)...)
+	messages = append(messages, userTurn("用户输入", userInput))
+
+	ctx = withTask(ctx, &task{Name: promptGroup, Input: userInput, Groups: candidates, Processors: processors})
+
+	var result model.GroupMatchResult
+	if err := c.ChatWithJSON(ctx, messages, &result); err != nil {
+		return nil, fmt.Errorf("分组匹配失败: %w", err)
+	}
+
+	return &result, nil
+}
+
+// RouteProcessors 分组优先的两阶段匹配：先选出分组，再只在分组内匹配处理器
+// 置信度达到 minConfidence 的最高分组会被选中，与它相差不超过 margin 的分组一并保留；
+// 分组只有一个、没有分组达到阈值或分组内没有匹配时，退回到对全部处理器的匹配
+func (c *Client) RouteProcessors(ctx context.Context, userInput string, groups []model.Group, processors []model.Processor, minConfidence, margin float64) (*model.ProcessorMatchResult, error) {
+	if len(groups) <= 1 {
+		return c.MatchProcessors(ctx, userInput, processors)
+	}
+
+	groupResult, err := c.MatchGroups(ctx, userInput, groups, processors)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]model.GroupMatch, len(groupResult.Groups))
+	copy(sorted, groupResult.Groups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Confidence > sorted[j].Confidence
+	})
+
+	selected := make(map[string]bool)
+	var selectedIDs []string
+	for _, g := range sorted {
+		if g.Confidence < minConfidence || (len(selectedIDs) > 0 && sorted[0].Confidence-g.Confidence > margin) {
+			break
+		}
+		if !selected[g.GroupID] {
+			selected[g.GroupID] = true
+			selectedIDs = append(selectedIDs, g.GroupID)
+		}
+	}
+
+	var scoped []model.Processor
+	for _, p := range processors {
+		if selected[p.Group] {
+			scoped = append(scoped, p)
+		}
+	}
+	if len(scoped) == 0 {
+		return c.MatchProcessors(ctx, userInput, processors)
+	}
+
+	result, err := c.MatchProcessors(ctx, userInput, scoped)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Matches) == 0 {
+		// 分组选错时不至于直接失败
+		return c.MatchProcessors(ctx, userInput, processors)
+	}
+
+	result.Groups = selectedIDs
+	return result, nil
+}
+
+// groupExampleTurns 用处理器的示例构造分组匹配的few-shot对话
+func groupExampleTurns(processors []model.Processor, maxPerProcessor int) []ChatMessage {
+	var turns []ChatMessage
+	for _, p := range processors {
+		if !p.Enabled {
+			continue
+		}
+		for i, ex := range p.Examples {
+			if i >= maxPerProcessor {
+				break
+			}
+			answer := model.GroupMatchResult{
+				Groups: []model.GroupMatch{
+					{GroupID: p.Group, Confidence: 0.95, Reason: "与示例说法一致"},
+				},
+			}
+			turns = append(turns,
+				userTurn("用户输入", ex.Input),
+				ChatMessage{Role: "assistant", Content: toJSON(answer)},
+			)
+		}
+	}
+	return turns
+}
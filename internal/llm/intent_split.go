@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// sequenceMarkers 表示先后执行多个动作的连接词，出现即可能包含多条指令
+var sequenceMarkers = []string{
+	"然后", "并且", "同时", "接着", "随后", "顺便", "另外",
+	"之后再", "以后再", "再把", "再帮", "再给",
+}
+
+// clauseSeparators 分句标记，后面紧跟一个动作时可能是另一条指令（如"关掉客厅灯并把卧室空调调到26度"）
+// "和"、"跟"、"、"连接的通常是同一动作的多个对象（如"把客厅灯和台灯打开"），不作为分句标记
+var clauseSeparators = []string{"并", "，", ",", "；", ";", "。"}
+
+// actionPrefixes 指令开头常见的动词和介词
+var actionPrefixes = []string{
+	"把", "将", "帮我", "再", "开", "关", "调", "设", "切换", "拉", "播放", "暂停", "启动", "停止", "更新",
+}
+
+// mayContainMultipleIntents 粗略判断输入是否可能包含多条指令
+// 不可能包含多条指令的输入直接视为单条指令，省去一次LLM调用
+func mayContainMultipleIntents(userInput string) bool {
+	for _, marker := range sequenceMarkers {
+		if strings.Contains(userInput, marker) {
+			return true
+		}
+	}
+	for _, sep := range clauseSeparators {
+		rest := userInput
+		for {
+			i := strings.Index(rest, sep)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(sep):]
+			if startsWithAction(strings.TrimSpace(rest)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startsWithAction 文本是否以动作开头
+func startsWithAction(text string) bool {
+	for _, prefix := range actionPrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitIntents 将一句话拆分为按执行顺序排列的子指令列表
+// 如果输入只包含一条指令，返回只有一个元素的列表
+func (c *Client) SplitIntents(ctx context.Context, userInput string) ([]string, error) {
+	userInput = strings.TrimSpace(userInput)
+	if !mayContainMultipleIntents(userInput) {
+		return []string{userInput}, nil
+	}
+
+	systemPrompt, err := c.renderPrompt(promptSplit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		userTurn("用户输入", userInput),
+	}
+
+	ctx = withTask(ctx, &task{Name: promptSplit, Input: userInput})
+
+	var result model.IntentSplitResult
+	if err := c.ChatWithJSON(ctx, messages, &result); err != nil {
+		return nil, fmt.Errorf("意图拆分失败: %w", err)
+	}
+
+	var commands []string
+	for _, cmd := range result.Commands {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			commands = append(commands, cmd)
+		}
+	}
+	if len(commands) == 0 {
+		return []string{userInput}, nil
+	}
+
+	return commands, nil
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMayContainMultipleIntents(t *testing.T) {
+	cases := []struct {
+		input string
+		want  bool
+	}{
+		{"打开客厅灯", false},
+		{"把客厅灯和台灯打开", false},
+		{"把客厅和卧室的灯都打开", false},
+		{"把卧室灯跟客厅灯都关了", false},
+		{"调亮一点，谢谢", false},
+		{"好的，谢谢", false},
+		{"打开灯，亮度50", false},
+		{"再亮一点", false},
+		{"十分钟之后关灯", false},
+		{"关掉客厅灯并把卧室空调调到26度", true},
+		{"关掉客厅灯并把电视也关了", true},
+		{"关掉客厅灯并关闭电视", true},
+		{"关灯，把空调调到26度", true},
+		{"打开空调；关掉加湿器", true},
+		{"打开客厅灯然后关闭空调", true},
+		{"关灯再把窗帘拉上", true},
+		{"打开空调并且调到26度", true},
+		{"开灯，同时打开电视", true},
+		{"关掉空调，顺便把灯也关了", true},
+	}
+	for _, tc := range cases {
+		if got := mayContainMultipleIntents(tc.input); got != tc.want {
+			t.Errorf("mayContainMultipleIntents(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+// failingTransport 任何调用都失败，用于确认没有发起LLM调用
+type failingTransport struct {
+	calls int
+}
+
+func (f *failingTransport) RoundTrip(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	f.calls++
+	return nil, context.Canceled
+}
+
+func TestSplitIntentsSkipsSingleCommand(t *testing.T) {
+	transport := &failingTransport{}
+	c := &Client{transport: transport}
+
+	commands, err := c.SplitIntents(context.Background(), " 把客厅灯和台灯打开，谢谢 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 1 || commands[0] != "把客厅灯和台灯打开，谢谢" {
+		t.Errorf("commands = %q", commands)
+	}
+	if transport.calls != 0 {
+		t.Errorf("单条指令不应调用LLM，实际调用 %d 次", transport.calls)
+	}
+}
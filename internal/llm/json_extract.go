@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// fencedBlockRe 匹配Markdown代码块（```json ... ``` 或 ``` ... ```），末尾的```可以缺失（输出被截断）
+var fencedBlockRe = regexp.MustCompile("(?s)```[a-zA-Z]*[ \t]*\n?(.*?)(?:```|$)")
+
+// extractJSON 从LLM响应文本中提取JSON
+// 依次尝试：代码块中的JSON、正文中括号平衡的完整JSON；存在多个候选时取最后一个（LLM常在真正的结果之前给出示例）。
+// 没有任何完整的JSON时才补全被截断的JSON，此时 repaired 为true；找不到时返回空字符串
+func extractJSON(s string) (jsonStr string, repaired bool) {
+	blocks := fencedBlockRe.FindAllStringSubmatch(s, -1)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if candidates, _ := scanJSON(blocks[i][1]); len(candidates) > 0 {
+			return candidates[len(candidates)-1], false
+		}
+	}
+
+	candidates, truncated := scanJSON(s)
+	if len(candidates) > 0 {
+		return candidates[len(candidates)-1], false
+	}
+	if truncated != "" {
+		return truncated, true
+	}
+	return "", false
+}
+
+// scanJSON 扫描文本中所有顶层的完整JSON对象/数组
+// 扫描时识别字符串和转义字符，字符串内的括号不参与匹配；
+// 文本末尾被截断的JSON单独补全后通过 truncated 返回（没有时为空）
+func scanJSON(s string) (candidates []string, truncated string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' && s[i] != '[' {
+			continue
+		}
+
+		scan := scanBalanced(s, i)
+		if scan.mismatch {
+			continue
+		}
+		if scan.end >= 0 {
+			candidate := removeTrailingCommas(s[i : scan.end+1])
+			if json.Valid([]byte(candidate)) {
+				candidates = append(candidates, candidate)
+				i = scan.end
+			}
+			continue
+		}
+
+		// 括号直到文本结束都没有闭合，按截断输出尝试补全；
+		// 补全失败说明这只是正文中的普通括号，继续向后扫描
+		if repaired := repairTruncated(s[i:], scan); repaired != "" {
+			truncated = repaired
+			break
+		}
+	}
+	return candidates, truncated
+}
+
+// balancedScan 括号扫描结果
+type balancedScan struct {
+	// end 匹配的结束位置（含），未闭合时为-1
+	end int
+
+	// mismatch 是否遇到不配对的括号
+	mismatch bool
+
+	// stack 未闭合时剩余的括号栈
+	stack []byte
+
+	// inString 未闭合时是否停在字符串内
+	inString bool
+
+	// lastComma 最后一个位于字符串外的逗号位置（相对起始位置），用于截断补全
+	lastComma int
+
+	// commaStack 最后一个逗号处的括号栈
+	commaStack []byte
+}
+
+// scanBalanced 从start位置（'{' 或 '['）开始扫描匹配的结束括号
+func scanBalanced(s string, start int) balancedScan {
+	result := balancedScan{end: -1, lastComma: -1}
+	var stack []byte
+	inString := false
+	escaped := false
+
+	for i := start; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, ch)
+		case '}', ']':
+			if len(stack) == 0 || !bracketsMatch(stack[len(stack)-1], ch) {
+				// 括号不匹配，不是合法JSON
+				return balancedScan{end: -1, mismatch: true}
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				result.end = i
+				return result
+			}
+		case ',':
+			result.lastComma = i - start
+			result.commaStack = append([]byte(nil), stack...)
+		}
+	}
+
+	result.stack = stack
+	result.inString = inString
+	return result
+}
+
+// bracketsMatch 判断开闭括号是否配对
+func bracketsMatch(open, close byte) bool {
+	return (open == '{' && close == '}') || (open == '[' && close == ']')
+}
+
+// closers 按括号栈生成补全用的闭合括号
+func closers(stack []byte) string {
+	var b strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			b.WriteByte('}')
+		} else {
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+// repairTruncated 补全被截断的JSON（如达到max_tokens时的输出）
+// 截断处的值完整时直接闭合括号；否则（停在字符串或数字中间）丢弃最后一个不完整的元素再闭合，
+// 避免把"调到2"这样的半截内容当作参数
+func repairTruncated(s string, scan balancedScan) string {
+	trimmed := strings.TrimRight(s, " \t\r\n,")
+	if !scan.inString && trimmed != "" && strings.IndexByte(`}]"el`, trimmed[len(trimmed)-1]) >= 0 {
+		attempt := removeTrailingCommas(trimmed + closers(scan.stack))
+		if json.Valid([]byte(attempt)) {
+			return attempt
+		}
+	}
+
+	if scan.lastComma >= 0 {
+		attempt := removeTrailingCommas(s[:scan.lastComma] + closers(scan.commaStack))
+		if json.Valid([]byte(attempt)) {
+			return attempt
+		}
+	}
+	return ""
+}
+
+// removeTrailingCommas 删除对象/数组末尾多余的逗号（如 {"a": 1,}），字符串内的内容不受影响
+func removeTrailingCommas(s string) string {
+	var b strings.Builder
+	inString := false
+	escaped := false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			b.WriteByte(ch)
+			continue
+		}
+
+		if ch == '"' {
+			inString = true
+		}
+		if ch == ',' {
+			j := i + 1
+			for j < len(s) && strings.IndexByte(" \t\r\n", s[j]) >= 0 {
+				j++
+			}
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				continue
+			}
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
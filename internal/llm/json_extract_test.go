@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// TestExtractJSONCorpus 用 testdata/malformed 中收集的LLM异常输出测试JSON提取
+// 每个用例由 <name>.txt（LLM原始输出）和 <name>.json（期望提取的JSON，为空表示没有JSON）组成，
+// 名称以 truncated_ 开头的用例期望结果是补全的
+func TestExtractJSONCorpus(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "malformed", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("testdata/malformed 中没有用例")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".txt")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(input, ".txt") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, repaired := extractJSON(string(raw))
+			if !sameJSON(t, got, string(bytes.TrimSpace(want))) {
+				t.Errorf("extractJSON() = %q, want %q", got, bytes.TrimSpace(want))
+			}
+			if wantRepaired := strings.HasPrefix(name, "truncated_"); repaired != wantRepaired {
+				t.Errorf("extractJSON() repaired = %v, want %v", repaired, wantRepaired)
+			}
+		})
+	}
+}
+
+func TestScanJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      []string
+		truncated string
+	}{
+		{"multiple", `a {"x": 1} b [2] c`, []string{`{"x": 1}`, `[2]`}, ""},
+		{"mismatched", `{"x": 1] {"y": 2}`, []string{`{"y": 2}`}, ""},
+		{"invalid", `{x: 1}`, nil, ""},
+		{"truncated last", `{"x": 1} {"y": [1, 2`, []string{`{"x": 1}`}, `{"y": [1]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := scanJSON(tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("scanJSON() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if !sameJSON(t, got[i], tt.want[i]) {
+					t.Errorf("scanJSON()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+			if !sameJSON(t, truncated, tt.truncated) {
+				t.Errorf("scanJSON() truncated = %q, want %q", truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestRepairTruncated(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"a": 1, "b": "x`, `{"a": 1}`},
+		{`{"a": 1, "b": true`, `{"a": 1, "b": true}`},
+		{`{"a": {"b": [1, 2`, `{"a": {"b": [1]}}`},
+		{`{"a": "only`, ``},
+		{`{"a": 1,`, `{"a": 1}`},
+	}
+
+	for _, tt := range tests {
+		got := repairTruncated(tt.input, scanBalanced(tt.input, 0))
+		if !sameJSON(t, got, tt.want) {
+			t.Errorf("repairTruncated(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"a": 1,}`, `{"a": 1}`},
+		{"[1, 2 ,\n]", "[1, 2 \n]"},
+		{`{"a": ",}"}`, `{"a": ",}"}`},
+	}
+
+	for _, tt := range tests {
+		if got := removeTrailingCommas(tt.input); got != tt.want {
+			t.Errorf("removeTrailingCommas(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// sameJSON 比较两个JSON是否等价（忽略空白），都为空时视为相同
+func sameJSON(t *testing.T, got, want string) bool {
+	t.Helper()
+	if got == "" || want == "" {
+		return got == want
+	}
+	var g, w bytes.Buffer
+	if err := json.Compact(&g, []byte(got)); err != nil {
+		t.Errorf("结果不是合法JSON: %q: %v", got, err)
+		return false
+	}
+	if err := json.Compact(&w, []byte(want)); err != nil {
+		t.Fatalf("期望值不是合法JSON: %q: %v", want, err)
+	}
+	return g.String() == w.String()
+}
+
+// scriptedTransport 依次返回预设的LLM输出
+type scriptedTransport struct {
+	outputs []string
+	calls   int
+}
+
+func (s *scriptedTransport) RoundTrip(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	out := s.outputs[len(s.outputs)-1]
+	if s.calls < len(s.outputs) {
+		out = s.outputs[s.calls]
+	}
+	s.calls++
+	return newMockResponse(out), nil
+}
+
+func TestExtractParametersTruncated(t *testing.T) {
+	processor := model.Processor{ID: "light", Name: "灯光", Parameters: []model.Parameter{{Name: "room", Type: "string"}, {Name: "brightness", Type: "number"}}}
+	truncated := `{"success": true, "parameters": {"room": "客厅", "brightness": 8`
+
+	tests := []struct {
+		name        string
+		outputs     []string
+		wantSuccess bool
+		wantParams  string
+	}{
+		// 修复轮仍被截断：不能执行，请用户重新说一次
+		{"still truncated", []string{truncated}, false, `{"room": "客厅"}`},
+		// 修复轮返回完整结果：使用完整结果，不残留补全的字段
+		{"retry complete", []string{truncated, `{"success": true, "parameters": {"brightness": 80}}`}, true, `{"brightness": 80}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts, err := loadPrompts("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := &scriptedTransport{outputs: tt.outputs}
+			c := &Client{transport: transport, prompts: prompts}
+
+			result, err := c.ExtractParameters(context.Background(), "客厅灯调到80", processor, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Success != tt.wantSuccess {
+				t.Errorf("Success = %v, want %v (message: %s)", result.Success, tt.wantSuccess, result.Message)
+			}
+			got, _ := json.Marshal(result.Parameters)
+			if !sameJSON(t, string(got), tt.wantParams) {
+				t.Errorf("Parameters = %s, want %s", got, tt.wantParams)
+			}
+			if transport.calls != 2 {
+				t.Errorf("LLM调用 %d 次，期望 2 次（截断后修复一次）", transport.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// ProviderMock 内置规则模拟的LLM提供方
+const ProviderMock = "mock"
+
+// taskKey context中保存task的键
+type taskKey struct{}
+
+// task 当前LLM调用的任务信息
+// 真实的LLM只看提示词，mock等本地Transport根据任务信息直接生成应答
+type task struct {
+	// Name 任务名称（与提示词模板名称一致）
+	Name string
+
+	// Input 用户输入
+	Input string
+
+	// Processors 候选处理器（意图匹配）
+	Processors []model.Processor
+
+	// Groups 候选分组（分组匹配）
+	Groups []model.Group
+
+	// Processor 目标处理器（参数提取/补全/修正）
+	Processor *model.Processor
+
+	// Known 已有的参数
+	Known map[string]interface{}
+
+	// Missing 缺少的参数
+	Missing []string
+}
+
+// withTask 返回携带任务信息的context
+func withTask(ctx context.Context, t *task) context.Context {
+	return context.WithValue(ctx, taskKey{}, t)
+}
+
+// taskFrom 从context中取出任务信息
+func taskFrom(ctx context.Context) *task {
+	t, _ := ctx.Value(taskKey{}).(*task)
+	return t
+}
+
+// mockEnumSynonyms 常见枚举值的中文说法
+var mockEnumSynonyms = map[string][]string{
+	"on":             {"打开", "开启", "开"},
+	"off":            {"关闭", "关掉", "关"},
+	"dim":            {"调暗", "暗一点", "暗"},
+	"brighten":       {"调亮", "亮一点", "亮"},
+	"open":           {"拉开", "打开", "开"},
+	"close":          {"拉上", "关上", "关闭", "关"},
+	"stop":           {"停止", "停"},
+	"set_temp":       {"温度", "度"},
+	"set_mode":       {"模式"},
+	"set_fan":        {"风速"},
+	"set_brightness": {"亮度"},
+	"set_color_temp": {"色温"},
+	"set_position":   {"位置"},
+	"cool":           {"制冷", "冷风", "冷"},
+	"heat":           {"制热", "暖风", "热"},
+	"dry":            {"除湿"},
+	"fan":            {"送风"},
+	"auto":           {"自动"},
+	"low":            {"低速", "小风", "低"},
+	"medium":         {"中速", "中"},
+	"high":           {"高速", "大风", "高"},
+	"home":           {"回家"},
+	"away":           {"离家", "出门"},
+	"sleep":          {"睡眠", "睡觉"},
+	"wake":           {"起床"},
+	"movie":          {"观影", "看电影"},
+	"reading":        {"阅读", "看书"},
+	"romantic":       {"浪漫"},
+	"party":          {"聚会", "派对"},
+	"global":         {"全局"},
+	"rule":           {"规则"},
+	"direct":         {"直连"},
+	"all":            {"全部", "所有"},
+	"proxy":          {"代理"},
+}
+
+// mockNumberRe 匹配输入中的数字
+var mockNumberRe = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+// mockSplitRe 拆分多条指令的分隔符
+var mockSplitRe = regexp.MustCompile(`[，,；;。]|并且|然后|同时|顺便|并`)
+
+// mockExampleListRe 从参数描述中提取候选值（如"房间名称，如：客厅、卧室、厨房"）
+var mockExampleListRe = regexp.MustCompile(`[如例][：:]\s*(.+)$`)
+
+// mockTransport 内置的规则模拟LLM
+// 根据处理器的关键词、示例、参数定义和 patterns 生成与真实LLM相同格式的JSON应答，
+// 用于本地开发和CI，不访问任何外部服务
+type mockTransport struct{}
+
+// RoundTrip 按任务类型生成应答
+func (t *mockTransport) RoundTrip(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var answer interface{} = map[string]interface{}{}
+
+	if tk := taskFrom(ctx); tk != nil {
+		switch tk.Name {
+		case promptMatch:
+			answer = mockMatch(tk.Input, tk.Processors)
+		case promptGroup:
+			answer = mockGroups(tk.Input, tk.Groups, tk.Processors)
+		case promptExtract:
+			answer = mockExtract(tk.Input, *tk.Processor, nil)
+		case promptFill:
+			answer = mockExtract(tk.Input, *tk.Processor, tk.Missing)
+		case promptSplit:
+			answer = mockSplit(tk.Input)
+		case promptReply:
+			answer = mockReply(tk.Processor, tk.Input)
+		case promptCorrect:
+			answer = model.ParameterExtractionResult{
+				Success:    false,
+				Parameters: tk.Known,
+				Message:    "模拟模式不支持参数修正",
+			}
+		}
+	}
+
+	// 回复生成等任务直接返回文本
+	if text, ok := answer.(string); ok {
+		return newMockResponse(text), nil
+	}
+
+	content, _ := json.Marshal(answer)
+	return newMockResponse(string(content)), nil
+}
+
+// newMockResponse 构造只有一个choice的对话响应
+func newMockResponse(content string) *ChatResponse {
+	var resp ChatResponse
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":     "mock",
+		"object": "chat.completion",
+		"model":  ProviderMock,
+		"choices": []map[string]interface{}{
+			{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"},
+		},
+	})
+	json.Unmarshal(data, &resp)
+	return &resp
+}
+
+// mockMatch 按示例和关键词为处理器打分
+func mockMatch(input string, processors []model.Processor) model.ProcessorMatchResult {
+	lower := strings.ToLower(input)
+	var matches []model.ProcessorMatch
+
+	for _, p := range processors {
+		score := 0
+		var hits []string
+		for _, ex := range p.Examples {
+			if strings.EqualFold(strings.TrimSpace(ex.Input), strings.TrimSpace(input)) {
+				score += 100
+				hits = append(hits, "示例")
+			}
+		}
+		for _, kw := range p.Keywords {
+			if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+				score += utf8.RuneCountInString(kw)
+				hits = append(hits, kw)
+			}
+		}
+		if p.Name != "" && strings.Contains(input, p.Name) {
+			score += 3
+			hits = append(hits, p.Name)
+		}
+		if score == 0 {
+			continue
+		}
+
+		confidence := 0.55 + 0.1*float64(score)
+		if confidence > 0.95 {
+			confidence = 0.95
+		}
+		matches = append(matches, model.ProcessorMatch{
+			ProcessorID: p.ID,
+			Confidence:  confidence,
+			Reason:      "命中: " + strings.Join(hits, "、"),
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+	if len(matches) > 3 {
+		matches = matches[:3]
+	}
+	if matches == nil {
+		matches = []model.ProcessorMatch{}
+	}
+	return model.ProcessorMatchResult{Matches: matches}
+}
+
+// mockGroups 以分组关键词和分组内处理器的匹配分数为分组打分
+func mockGroups(input string, groups []model.Group, processors []model.Processor) model.GroupMatchResult {
+	best := make(map[string]model.ProcessorMatch)
+	for _, m := range mockMatch(input, processors).Matches {
+		for _, p := range processors {
+			if p.ID == m.ProcessorID && m.Confidence > best[p.Group].Confidence {
+				best[p.Group] = m
+			}
+		}
+	}
+
+	lower := strings.ToLower(input)
+	result := model.GroupMatchResult{Groups: []model.GroupMatch{}}
+	for _, g := range groups {
+		confidence := best[g.ID].Confidence
+		reason := best[g.ID].Reason
+		for _, kw := range g.Keywords {
+			if kw != "" && strings.Contains(lower, strings.ToLower(kw)) && confidence < 0.9 {
+				confidence, reason = 0.9, "命中分组关键词: "+kw
+			}
+		}
+		if confidence > 0 {
+			result.Groups = append(result.Groups, model.GroupMatch{GroupID: g.ID, Confidence: confidence, Reason: reason})
+		}
+	}
+
+	sort.SliceStable(result.Groups, func(i, j int) bool { return result.Groups[i].Confidence > result.Groups[j].Confidence })
+	return result
+}
+
+// mockReply 把执行结果中的字段逐个列出
+func mockReply(processor *model.Processor, data string) string {
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &result); err != nil || len(result) == 0 {
+		return processor.Name + "已执行完成"
+	}
+
+	keys := make([]string, 0, len(result))
+	for k := range result {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s为%v", k, result[k]))
+	}
+	return fmt.Sprintf("%s已执行完成，%s", processor.Name, strings.Join(pairs, "，"))
+}
+
+// mockExtract 按参数定义从输入中提取参数；only 非空时只提取其中的参数
+func mockExtract(input string, processor model.Processor, only []string) model.ParameterExtractionResult {
+	params := make(map[string]interface{})
+
+	// 与示例完全一致时直接使用示例的参数
+	for _, ex := range processor.Examples {
+		if strings.EqualFold(strings.TrimSpace(ex.Input), strings.TrimSpace(input)) {
+			for k, v := range ex.Parameters {
+				params[k] = v
+			}
+		}
+	}
+
+	numbers := mockNumberRe.FindAllString(input, -1)
+	for _, p := range processor.Parameters {
+		if len(only) > 0 && !containsString(only, p.Name) {
+			continue
+		}
+		if _, ok := params[p.Name]; ok {
+			continue
+		}
+
+		if v, ok := mockByPatterns(input, p.Patterns); ok {
+			params[p.Name] = v
+			continue
+		}
+
+		switch p.Type {
+		case "enum":
+			if v := mockEnum(input, p.Values); v != "" {
+				params[p.Name] = v
+			}
+		case "int", "integer", "float", "number":
+			if len(numbers) > 0 {
+				params[p.Name], _ = strconv.ParseFloat(numbers[0], 64)
+				numbers = numbers[1:]
+			}
+		case "string":
+			if v := mockCandidate(input, p, processor.Examples); v != "" {
+				params[p.Name] = v
+			}
+		}
+	}
+
+	var missing []string
+	for _, p := range processor.Parameters {
+		if len(only) > 0 && !containsString(only, p.Name) {
+			continue
+		}
+		if _, ok := params[p.Name]; !ok && p.Required {
+			missing = append(missing, p.Name)
+		}
+	}
+
+	result := model.ParameterExtractionResult{
+		Success:         len(missing) == 0,
+		Parameters:      params,
+		MissingRequired: missing,
+	}
+	if missing == nil {
+		result.MissingRequired = []string{}
+	}
+	return result
+}
+
+// mockByPatterns 使用参数的正则表达式提取
+func mockByPatterns(input string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		m := re.FindStringSubmatch(input)
+		if m == nil {
+			continue
+		}
+		if len(m) > 1 {
+			return m[1], true
+		}
+		return m[0], true
+	}
+	return "", false
+}
+
+// mockEnum 在输入中查找枚举值或其中文说法，取最长的命中
+func mockEnum(input string, values []string) string {
+	lower := strings.ToLower(input)
+	best, bestLen := "", 0
+	for _, v := range values {
+		candidates := append([]string{v}, mockEnumSynonyms[v]...)
+		for _, c := range candidates {
+			if n := utf8.RuneCountInString(c); strings.Contains(lower, strings.ToLower(c)) && n > bestLen {
+				best, bestLen = v, n
+			}
+		}
+	}
+	return best
+}
+
+// mockCandidate 从参数描述中的举例和处理器示例中收集候选值，取输入中出现的最长候选
+func mockCandidate(input string, p model.Parameter, examples []model.Example) string {
+	var candidates []string
+	if m := mockExampleListRe.FindStringSubmatch(p.Description); m != nil {
+		for _, c := range strings.FieldsFunc(m[1], func(r rune) bool {
+			return strings.ContainsRune("、，,/ 。）)", r)
+		}) {
+			candidates = append(candidates, c)
+		}
+	}
+	for _, ex := range examples {
+		if v, ok := ex.Parameters[p.Name].(string); ok {
+			candidates = append(candidates, v)
+		}
+	}
+
+	best := ""
+	for _, c := range candidates {
+		if c != "" && strings.Contains(input, c) && utf8.RuneCountInString(c) > utf8.RuneCountInString(best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// mockSplit 按连接词和标点拆分指令
+func mockSplit(input string) model.IntentSplitResult {
+	var commands []string
+	for _, part := range mockSplitRe.Split(input, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			commands = append(commands, part)
+		}
+	}
+	if len(commands) == 0 {
+		commands = []string{input}
+	}
+	return model.IntentSplitResult{Commands: commands}
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
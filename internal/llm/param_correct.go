@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// CorrectParameters 参数校验失败时，把校验错误反馈给LLM重新提取一次参数
+func (c *Client) CorrectParameters(ctx context.Context, userInput string, processor model.Processor, params map[string]interface{}, validationErr error) (*model.ParameterExtractionResult, error) {
+	systemPrompt, err := c.renderProcessorPrompt(promptCorrect, processor, struct {
+		Processor  model.Processor
+		Parameters map[string]interface{}
+		Error      string
+	}{Processor: processor, Parameters: params, Error: validationErr.Error()})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []ChatMessage{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, extractExampleTurns(processor)...)
+	messages = append(messages, userTurn("用户输入", userInput))
+
+	ctx = withTask(ctx, &task{Name: promptCorrect, Input: userInput, Processor: &processor, Known: params})
+
+	var result model.ParameterExtractionResult
+	if err := c.ChatWithJSON(ctx, messages, &result); err != nil {
+		if !errors.Is(err, ErrTruncated) {
+			return nil, fmt.Errorf("参数修正失败: %w", err)
+		}
+		markTruncated(&result)
+	}
+
+	return &result, nil
+}
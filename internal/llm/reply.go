@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// SummarizeResult 使用LLM把后端返回的结构化结果转换为面向用户的回复
+// markdown 为true时允许使用Telegram Markdown格式
+func (c *Client) SummarizeResult(ctx context.Context, processor model.Processor, params map[string]interface{}, result interface{}, markdown bool) (string, error) {
+	systemPrompt, err := c.renderPrompt(promptReply, struct {
+		Processor  model.Processor
+		Parameters map[string]interface{}
+		Markdown   bool
+	}{Processor: processor, Parameters: params, Markdown: markdown})
+	if err != nil {
+		return "", err
+	}
+
+	data := toJSON(result)
+	messages := []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		userTurn("执行结果", data),
+	}
+
+	ctx = withTask(ctx, &task{Name: promptReply, Input: data, Processor: &processor, Known: params})
+
+	content, err := c.Chat(ctx, messages)
+	if err != nil {
+		return "", fmt.Errorf("生成回复失败: %w", err)
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", fmt.Errorf("生成回复失败: LLM返回空内容")
+	}
+	return content, nil
+}
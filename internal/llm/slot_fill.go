@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// FillParameters 从用户对追问的回答中提取缺失的参数
+// known 为此前已收集的参数，missing 为仍缺少的必填参数
+// 返回结果中的parameters只包含从本次回答中新提取的参数
+func (c *Client) FillParameters(ctx context.Context, answer string, processor model.Processor, known map[string]interface{}, missing []string) (*model.ParameterExtractionResult, error) {
+	systemPrompt, err := c.renderProcessorPrompt(promptFill, processor, struct {
+		Processor model.Processor
+		Known     map[string]interface{}
+		Missing   []string
+	}{Processor: processor, Known: known, Missing: missing})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		userTurn("用户回答", answer),
+	}
+
+	ctx = withTask(ctx, &task{Name: promptFill, Input: answer, Processor: &processor, Known: known, Missing: missing})
+
+	var result model.ParameterExtractionResult
+	if err := c.ChatWithJSON(ctx, messages, &result); err != nil {
+		if !errors.Is(err, ErrTruncated) {
+			return nil, fmt.Errorf("参数补全失败: %w", err)
+		}
+		markTruncated(&result)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Transport 执行一次对话请求
+// 默认通过HTTP调用OpenAI兼容接口，录制/回放等模式通过包装Transport实现
+type Transport interface {
+	RoundTrip(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+// httpTransport 通过HTTP调用OpenAI兼容接口
+type httpTransport struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// RoundTrip 发送HTTP请求并解析响应
+func (t *httpTransport) RoundTrip(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	var chatResp ChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w, 原始响应: %s", err, string(respBody))
+	}
+
+	return &chatResp, nil
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// usageKey context中保存Usage的键
+type usageKey struct{}
+
+// Usage 累计的LLM调用次数和token用量
+type Usage struct {
+	mu               sync.Mutex
+	calls            int
+	promptTokens     int
+	completionTokens int
+	totalTokens      int
+}
+
+// UsageStats Usage的快照
+type UsageStats struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// WithUsage 返回携带用量统计的context，使用该context的LLM调用都会累计到u中
+func WithUsage(ctx context.Context, u *Usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+// usageFrom 从context中取出用量统计
+func usageFrom(ctx context.Context) *Usage {
+	u, _ := ctx.Value(usageKey{}).(*Usage)
+	return u
+}
+
+// add 累计一次调用的用量
+func (u *Usage) add(resp *ChatResponse) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.calls++
+	u.promptTokens += resp.Usage.PromptTokens
+	u.completionTokens += resp.Usage.CompletionTokens
+	u.totalTokens += resp.Usage.TotalTokens
+}
+
+// Stats 获取当前用量
+func (u *Usage) Stats() UsageStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return UsageStats{
+		Calls:            u.calls,
+		PromptTokens:     u.promptTokens,
+		CompletionTokens: u.completionTokens,
+		TotalTokens:      u.totalTokens,
+	}
+}
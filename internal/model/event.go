@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// 事件级别
+const (
+	// SeverityInfo 一般通知（如洗衣机已完成）
+	SeverityInfo = "info"
+
+	// SeverityWarning 需要关注（如订阅更新失败）
+	SeverityWarning = "warning"
+
+	// SeverityCritical 紧急事件（如门长时间未关），免打扰时段也会推送
+	SeverityCritical = "critical"
+)
+
+// DeviceEvent 后端主动上报的设备事件
+type DeviceEvent struct {
+	// ID 事件ID
+	ID string `json:"id"`
+
+	// Type 事件类型（如 door_left_open, wash_finished, subscription_update_failed）
+	Type string `json:"type"`
+
+	// ProcessorID 来源处理器ID，订阅可以按处理器或其所属分组匹配
+	ProcessorID string `json:"processor_id"`
+
+	// Severity 事件级别: info（默认）, warning, critical
+	Severity string `json:"severity,omitempty"`
+
+	// Message 面向用户的文本，为空时由事件类型和数据生成
+	Message string `json:"message,omitempty"`
+
+	// Data 事件数据
+	Data interface{} `json:"data,omitempty"`
+
+	// OccurredAt 事件发生时间
+	OccurredAt time.Time `json:"occurred_at"`
+}
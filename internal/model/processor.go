@@ -1,5 +1,11 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Processor 处理器定义
 type Processor struct {
 	// ID 处理器唯一标识
@@ -20,16 +26,151 @@ type Processor struct {
 	// Parameters 参数定义列表
 	Parameters []Parameter `yaml:"parameters" json:"parameters"`
 
+	// Examples 示例说法及期望参数，作为few-shot示例提供给LLM
+	// 用于教会模型家里的习惯说法（如"主灯"指客厅灯）
+	Examples []Example `yaml:"examples,omitempty" json:"examples,omitempty"`
+
+	// Prompts 提示词模板覆盖，key为模板名称（extract, fill, correct），value为text/template模板
+	Prompts map[string]string `yaml:"prompts,omitempty" json:"prompts,omitempty"`
+
+	// Reply 回复模板（text/template），把后端返回的结构化结果转换为面向用户的回复
+	// 可用 .Result、.Parameters、.Processor、.Channel，以及 bold/italic/code/escape 等按渠道输出格式的函数
+	Reply string `yaml:"reply,omitempty" json:"reply,omitempty"`
+
+	// MinConfidence 匹配该处理器所需的最低置信度，为0时使用全局配置
+	MinConfidence float64 `yaml:"min_confidence,omitempty" json:"min_confidence,omitempty"`
+
+	// Sensitive 是否为敏感处理器（如门锁、安防）
+	// 敏感处理器的匹配需要通过更严格的检查（见 guard 配置），防止被诱导执行
+	Sensitive bool `yaml:"sensitive,omitempty" json:"sensitive,omitempty"`
+
+	// Dispatch 请求分发策略（目标topic、超时、是否等待响应、分区键），未设置时使用 kafka 全局配置
+	Dispatch Dispatch `yaml:"dispatch,omitempty" json:"dispatch,omitempty"`
+
 	// Enabled 是否启用
 	Enabled bool `yaml:"enabled" json:"enabled"`
 }
 
+// 分区键策略
+const (
+	// PartitionByTraceID 按TraceID分区（默认），请求均匀分布到各分区
+	PartitionByTraceID = "trace_id"
+
+	// PartitionByProcessor 按处理器分区，同一处理器的请求按顺序处理
+	PartitionByProcessor = "processor"
+
+	// PartitionByUser 按用户分区，同一用户的请求按顺序处理
+	PartitionByUser = "user"
+
+	// PartitionByChat 按会话分区，同一会话的请求按顺序处理
+	PartitionByChat = "chat"
+
+	// PartitionByParameterPrefix 按参数值分区，如 "param:room" 使同一房间的请求按顺序处理
+	PartitionByParameterPrefix = "param:"
+)
+
+// 分发后端
+const (
+	// BackendKafka 通过Kafka请求/响应topic分发（默认）
+	BackendKafka = "kafka"
+
+	// BackendHTTP 以HTTP POST发送到处理器的 dispatch.url
+	BackendHTTP = "http"
+
+	// BackendMQTT 通过MQTT请求/响应topic分发
+	BackendMQTT = "mqtt"
+
+	// BackendLocal 调用进程内注册的Go函数
+	BackendLocal = "local"
+)
+
+// Dispatch 处理器的请求分发策略
+type Dispatch struct {
+	// Backend 分发后端: kafka, http, mqtt, local，为空时使用 dispatch.backend
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// URL HTTP后端的请求地址（backend 为 http 时必填）
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Topic 请求发送到的topic（Kafka或MQTT），为空时使用对应后端配置的请求topic
+	Topic string `yaml:"topic,omitempty" json:"topic,omitempty"`
+
+	// ResponseTimeout 等待后端响应的超时时间，为0时使用 kafka.response_timeout
+	// 场景切换等即时操作可以设置得很短，重建配置等耗时操作需要设置得更长
+	ResponseTimeout time.Duration `yaml:"response_timeout,omitempty" json:"response_timeout,omitempty"`
+
+	// ExpectResponse 是否等待后端响应，设置为false时发送后立即返回（fire-and-forget）
+	ExpectResponse *bool `yaml:"expect_response,omitempty" json:"expect_response,omitempty"`
+
+	// PartitionKey 分区键策略: trace_id（默认）, processor, user, chat, param:<参数名>
+	PartitionKey string `yaml:"partition_key,omitempty" json:"partition_key,omitempty"`
+
+	// Queue Kafka不可用时是否允许写入本地队列、恢复后再发送
+	// 定时更新等可以延迟执行的操作可以开启；开关灯等即时操作延迟执行反而出乎意料，默认不开启
+	Queue bool `yaml:"queue,omitempty" json:"queue,omitempty"`
+}
+
+// WantsResponse 是否等待后端响应（默认等待）
+func (d Dispatch) WantsResponse() bool {
+	return d.ExpectResponse == nil || *d.ExpectResponse
+}
+
+// Validate 检查分发策略
+func (d Dispatch) Validate() error {
+	switch d.Backend {
+	case "", BackendKafka, BackendMQTT, BackendLocal:
+	case BackendHTTP:
+		if d.URL == "" {
+			return fmt.Errorf("http 后端需要设置 url")
+		}
+	default:
+		return fmt.Errorf("不支持的分发后端: %s", d.Backend)
+	}
+
+	switch {
+	case d.PartitionKey == "", d.PartitionKey == PartitionByTraceID, d.PartitionKey == PartitionByProcessor,
+		d.PartitionKey == PartitionByUser, d.PartitionKey == PartitionByChat:
+	case strings.HasPrefix(d.PartitionKey, PartitionByParameterPrefix) && len(d.PartitionKey) > len(PartitionByParameterPrefix):
+	default:
+		return fmt.Errorf("不支持的分区键策略: %s", d.PartitionKey)
+	}
+	if d.ResponseTimeout < 0 {
+		return fmt.Errorf("response_timeout 不能为负数")
+	}
+	return nil
+}
+
+// Group 处理器分组定义
+// 在处理器配置文件顶层的 group 中定义，用于分组优先的两阶段意图匹配
+type Group struct {
+	// ID 分组唯一标识（对应 Processor.Group）
+	ID string `yaml:"id" json:"id"`
+
+	// Name 分组名称（用于显示）
+	Name string `yaml:"name" json:"name"`
+
+	// Description 分组描述（用于LLM选择分组）
+	Description string `yaml:"description" json:"description"`
+
+	// Keywords 关键词列表（辅助选择分组）
+	Keywords []string `yaml:"keywords" json:"keywords"`
+}
+
+// Example 处理器示例
+type Example struct {
+	// Input 用户说法
+	Input string `yaml:"input" json:"input"`
+
+	// Parameters 期望提取的参数
+	Parameters map[string]interface{} `yaml:"parameters" json:"parameters"`
+}
+
 // Parameter 参数定义
 type Parameter struct {
 	// Name 参数名
 	Name string `yaml:"name" json:"name"`
 
-	// Type 参数类型: string, int, float, bool, enum
+	// Type 参数类型: string, int, float, bool, enum, datetime（RFC3339）
 	Type string `yaml:"type" json:"type"`
 
 	// Required 是否必填
@@ -46,15 +187,40 @@ type Parameter struct {
 
 	// Range 数值范围 [min, max]（当Type为int/float时使用）
 	Range []float64 `yaml:"range,omitempty" json:"range,omitempty"`
+
+	// Unit 数值参数的单位（如 celsius, percent），用于单位换算
+	Unit string `yaml:"unit,omitempty" json:"unit,omitempty"`
+
+	// Patterns 提取该参数的正则表达式（第一个捕获组为参数值，没有捕获组时取整个匹配）
+	// 用于 llm.provider: mock 时的本地规则提取
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+}
+
+// ProcessorMatch 单个处理器匹配项
+type ProcessorMatch struct {
+	ProcessorID string  `json:"processor_id"`
+	Confidence  float64 `json:"confidence"`
+	Reason      string  `json:"reason"`
 }
 
 // ProcessorMatchResult 处理器匹配结果
 type ProcessorMatchResult struct {
-	Matches []struct {
-		ProcessorID string  `json:"processor_id"`
-		Confidence  float64 `json:"confidence"`
-		Reason      string  `json:"reason"`
-	} `json:"matches"`
+	Matches []ProcessorMatch `json:"matches"`
+
+	// Groups 分组路由时选中的分组（由网关填充，不是LLM的输出）
+	Groups []string `json:"groups,omitempty"`
+}
+
+// GroupMatch 单个分组匹配项
+type GroupMatch struct {
+	GroupID    string  `json:"group_id"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+// GroupMatchResult 分组匹配结果
+type GroupMatchResult struct {
+	Groups []GroupMatch `json:"groups"`
 }
 
 // ParameterExtractionResult 参数提取结果
@@ -64,3 +230,9 @@ type ParameterExtractionResult struct {
 	MissingRequired []string               `json:"missing_required"`
 	Message         string                 `json:"message"`
 }
+
+// IntentSplitResult 意图拆分结果
+type IntentSplitResult struct {
+	// Commands 按执行顺序排列的子指令
+	Commands []string `json:"commands"`
+}
@@ -2,17 +2,31 @@ package model
 
 import "time"
 
+// SchemaVersion 请求/响应消息格式的版本，字段有不兼容的变更时递增
+// 对应的JSON Schema由网关在 /api/v1/schemas 下发布
+const SchemaVersion = "1"
+
 // KafkaRequest 发送到后端处理器的请求
 type KafkaRequest struct {
+	// SchemaVersion 消息格式版本
+	SchemaVersion string `json:"schema_version,omitempty"`
+
 	// TraceID 请求追踪ID
 	TraceID string `json:"trace_id"`
 
+	// ParentTraceID 父请求追踪ID（一句话拆分为多条子指令时，子指令共享父TraceID）
+	ParentTraceID string `json:"parent_trace_id,omitempty"`
+
 	// ProcessorID 目标处理器ID
 	ProcessorID string `json:"processor_id"`
 
 	// Parameters 提取的参数
 	Parameters map[string]interface{} `json:"parameters"`
 
+	// ReplyTo 响应应发送到的topic，为空时发送到默认的响应topic
+	// 多个网关实例共用Kafka时，后端按它回复，响应只会到达发出请求的实例
+	ReplyTo string `json:"reply_to,omitempty"`
+
 	// RawMessage 原始消息上下文
 	RawMessage UnifiedMessage `json:"raw_message"`
 
@@ -22,6 +36,9 @@ type KafkaRequest struct {
 
 // KafkaResponse 后端处理器返回的响应
 type KafkaResponse struct {
+	// SchemaVersion 消息格式版本，旧版后端不填
+	SchemaVersion string `json:"schema_version,omitempty"`
+
 	// TraceID 请求追踪ID
 	TraceID string `json:"trace_id"`
 
@@ -37,6 +54,33 @@ type KafkaResponse struct {
 	// Error 错误信息（如果Success为false）
 	Error string `json:"error,omitempty"`
 
+	// Status 消息类型: progress（进度）, partial（部分结果）, final（最终结果）
+	// 为空时视为 final，兼容只返回一条响应的后端
+	Status string `json:"status,omitempty"`
+
+	// Sequence 同一请求内的消息序号，从1开始递增，用于丢弃重复或乱序的中间消息
+	Sequence int `json:"sequence,omitempty"`
+
+	// Percent 进度百分比（0-100）
+	Percent float64 `json:"percent,omitempty"`
+
 	// ProcessedAt 处理完成时间
 	ProcessedAt time.Time `json:"processed_at"`
 }
+
+// 响应消息类型
+const (
+	// ResponseProgress 进度更新
+	ResponseProgress = "progress"
+
+	// ResponsePartial 部分结果
+	ResponsePartial = "partial"
+
+	// ResponseFinal 最终结果
+	ResponseFinal = "final"
+)
+
+// IsFinal 是否为最终结果
+func (r *KafkaResponse) IsFinal() bool {
+	return r.Status == "" || r.Status == ResponseFinal
+}
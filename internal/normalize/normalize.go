@@ -0,0 +1,129 @@
+package normalize
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// fahrenheitRe 华氏温度，如 "70华氏度"、"70°F"、"70℉"
+var fahrenheitRe = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s*(?:华氏度|华氏|°F|℉|F\b)`)
+
+// fahrenheitPrefixRe 华氏在数字前，如 "华氏70度"
+var fahrenheitPrefixRe = regexp.MustCompile(`华氏\s*(-?\d+(?:\.\d+)?)`)
+
+// numberSuffixes 数值参数中可以去掉的单位
+var numberSuffixes = []string{"摄氏度", "摄氏", "°C", "℃", "度", "%", "％"}
+
+// Text 在发送给LLM之前规范化用户输入：中文数字、百分比、零下温度转换为阿拉伯数字
+func Text(input string) string {
+	return Numerals(input)
+}
+
+// Parameters 在LLM提取参数之后、校验之前规范化参数值，使同一说法得到一致的结果：
+//   - int/float参数：中文数字和带单位的字符串（"二十六度"、"30%"）转换为数字；
+//     温度参数的值为华氏温度时（值本身带华氏单位，或等于输入中的华氏数值）转换为摄氏度
+//   - datetime参数：相对时间（"半小时后"、"明天早上7点"）转换为RFC3339
+//
+// input 为用户原始输入，now 为解析相对时间的基准时间。返回新的参数表
+func Parameters(processor *model.Processor, params map[string]interface{}, input string, now time.Time) map[string]interface{} {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+
+	normalized := Numerals(input)
+	for _, p := range processor.Parameters {
+		raw, ok := out[p.Name]
+		if !ok || raw == nil {
+			continue
+		}
+
+		switch p.Type {
+		case "int", "integer", "float", "number":
+			if v, ok := normalizeNumber(p, raw, normalized); ok {
+				out[p.Name] = v
+			}
+		case "datetime":
+			if s, ok := raw.(string); ok {
+				if t, ok := ParseTime(s, now); ok {
+					out[p.Name] = t.Format(time.RFC3339)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// normalizeNumber 规范化单个数值参数
+func normalizeNumber(p model.Parameter, raw interface{}, input string) (float64, bool) {
+	var f float64
+	fahrenheit := false
+
+	switch v := raw.(type) {
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case string:
+		s := strings.TrimSpace(Numerals(v))
+		if m := fahrenheitRe.FindStringSubmatch(s); m != nil {
+			s, fahrenheit = m[1], true
+		} else if m := fahrenheitPrefixRe.FindStringSubmatch(s); m != nil {
+			s, fahrenheit = m[1], true
+		}
+		for _, suffix := range numberSuffixes {
+			s = strings.TrimSpace(strings.TrimSuffix(s, suffix))
+		}
+		parsed, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false
+		}
+		f = parsed
+	default:
+		return 0, false
+	}
+
+	if !IsTemperature(p) {
+		return f, true
+	}
+
+	// LLM可能原样返回用户说的华氏数值
+	if !fahrenheit {
+		for _, re := range []*regexp.Regexp{fahrenheitRe, fahrenheitPrefixRe} {
+			for _, m := range re.FindAllStringSubmatch(input, -1) {
+				if v, err := strconv.ParseFloat(m[1], 64); err == nil && v == f {
+					fahrenheit = true
+				}
+			}
+		}
+	}
+	if !fahrenheit {
+		return f, true
+	}
+
+	c := FahrenheitToCelsius(f)
+	if p.Type == "int" || p.Type == "integer" {
+		return math.Round(c), true
+	}
+	return math.Round(c*10) / 10, true
+}
+
+// IsTemperature 判断参数是否为摄氏温度
+// 优先使用 unit 配置，未配置时按参数名判断（temp/temperature，不含色温）
+func IsTemperature(p model.Parameter) bool {
+	if p.Unit != "" {
+		return p.Unit == "celsius"
+	}
+	name := strings.ToLower(p.Name)
+	return strings.Contains(name, "temp") && !strings.Contains(name, "color") && !strings.Contains(name, "colour")
+}
+
+// FahrenheitToCelsius 华氏温度转换为摄氏温度
+func FahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
@@ -0,0 +1,238 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// chineseDigits 中文数字
+var chineseDigits = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// chineseUnits 中文数位
+var chineseUnits = map[rune]int{'十': 10, '百': 100, '千': 1000, '万': 10000}
+
+// numeralRunRe 连续的中文数字（可带小数部分）
+var numeralRunRe = regexp.MustCompile(`[零〇一二两三四五六七八九十百千万]+(?:点[零〇一二三四五六七八九]+)?`)
+
+// percentRe 百分之N
+var percentRe = regexp.MustCompile(`百分之([零〇一二两三四五六七八九十百点\d.]+)`)
+
+// clockTimeRe 中文时刻，如 "早上七点"、"七点五十分"（避免把"七点五"当作小数）
+var clockTimeRe = regexp.MustCompile(`(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上|今晚|明晚|今早|明早|今天|明天|后天)?([零〇一二两三四五六七八九十]+)点(?:([零〇一二三四五六七八九十]+)分)?`)
+
+// belowZeroRe 零下N度
+var belowZeroRe = regexp.MustCompile(`零下\s*(\d+(?:\.\d+)?)`)
+
+// numeralUnits 单个中文数字后面跟着这些量词/单位时才转换，避免把"亮一点"、"看一下"变成数字
+var numeralUnits = []string{
+	"度", "%", "分", "小时", "个", "秒", "档", "级", "摄氏", "华氏", "号", "月", "日", "天",
+	"周", "次", "年", "岁", "倍", "格", "点钟", "点半", "点整", "钟头",
+}
+
+// numeralIdioms 含数位的常用词，不表示数值，如 "千万别关"、"万一下雨"、"十分暗"
+var numeralIdioms = []string{"千万", "万一", "十分"}
+
+// Numerals 把文本中的中文数字转换为阿拉伯数字
+// 如 "二十六度" -> "26度"，"百分之三十" -> "30%"，"零下五度" -> "-5度"，"明天早上七点" -> "明天早上7点"
+func Numerals(s string) string {
+	s = percentRe.ReplaceAllStringFunc(s, func(m string) string {
+		inner := percentRe.FindStringSubmatch(m)[1]
+		if v, ok := parseChinese(inner); ok {
+			return v + "%"
+		}
+		return m
+	})
+
+	s = clockTimeRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := clockTimeRe.FindStringSubmatch(m)
+		if sub[1] == "" && sub[3] == "" {
+			// 没有时段也没有分钟，可能是"亮一点"，交给后面的规则判断
+			return m
+		}
+		hour, ok := parseChineseInt(sub[2])
+		if !ok {
+			return m
+		}
+		out := sub[1] + strconv.Itoa(hour) + "点"
+		if sub[3] != "" {
+			minute, ok := parseChineseInt(sub[3])
+			if !ok {
+				return m
+			}
+			out += strconv.Itoa(minute) + "分"
+		}
+		return out
+	})
+
+	s = replaceRuns(s)
+	return belowZeroRe.ReplaceAllString(s, "-$1")
+}
+
+// replaceRuns 逐个替换中文数字串
+func replaceRuns(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range numeralRunRe.FindAllStringIndex(s, -1) {
+		run := s[loc[0]:loc[1]]
+		rest := s[loc[1]:]
+		if !shouldConvert(run, rest) {
+			continue
+		}
+		v, ok := parseChinese(run)
+		if !ok {
+			continue
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(v)
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// shouldConvert 判断数字串是否确实表示数值
+// 含数字的多字串直接转换；单个数字或只有数位（"十"、"千万"）的串只有后面跟着单位时才转换
+func shouldConvert(run, rest string) bool {
+	if run == "零" && strings.HasPrefix(rest, "下") {
+		return false
+	}
+	if isNumeralIdiom(run + rest) {
+		return false
+	}
+	if hasDigit(run) && (strings.ContainsAny(run, "十百千万点") || len([]rune(run)) > 1) {
+		return true
+	}
+	return hasUnitSuffix(rest)
+}
+
+// isNumeralIdiom 判断文本是否以不表示数值的常用词开头（"十分钟"仍是时长）
+func isNumeralIdiom(text string) bool {
+	if strings.HasPrefix(text, "十分钟") {
+		return false
+	}
+	for _, idiom := range numeralIdioms {
+		if strings.HasPrefix(text, idiom) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDigit 判断数字串中是否有数字（而不只是数位）
+func hasDigit(run string) bool {
+	for _, r := range run {
+		if _, ok := chineseDigits[r]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnitSuffix 判断数字串后面是否跟着量词/单位
+func hasUnitSuffix(rest string) bool {
+	for _, unit := range numeralUnits {
+		if strings.HasPrefix(rest, unit) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChinese 解析中文数字（也接受阿拉伯数字），返回十进制字符串
+func parseChinese(s string) (string, bool) {
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s, true
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.Index(s, "点"); i >= 0 {
+		intPart, fracPart = s[:i], s[i+len("点"):]
+	}
+
+	n, ok := parseChineseInt(intPart)
+	if !ok {
+		return "", false
+	}
+	result := strconv.Itoa(n)
+
+	if fracPart != "" {
+		var digits strings.Builder
+		for _, r := range fracPart {
+			d, ok := chineseDigits[r]
+			if !ok {
+				return "", false
+			}
+			digits.WriteString(strconv.Itoa(d))
+		}
+		result += "." + digits.String()
+	}
+	return result, true
+}
+
+// parseChineseInt 解析中文整数，如 "二十六"、"一百零五"、"三千五"（3500）、"一二三"（123）
+func parseChineseInt(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	runes := []rune(s)
+	hasUnit := false
+	for _, r := range runes {
+		if _, ok := chineseUnits[r]; ok {
+			hasUnit = true
+		}
+	}
+
+	// 没有数位时逐位读（如房间号"一二三"）
+	if !hasUnit {
+		n := 0
+		for _, r := range runes {
+			d, ok := chineseDigits[r]
+			if !ok {
+				return 0, false
+			}
+			n = n*10 + d
+		}
+		return n, true
+	}
+
+	total, section, number := 0, 0, 0
+	lastUnit := 0
+	zero := false
+	for _, r := range runes {
+		if d, ok := chineseDigits[r]; ok {
+			if d == 0 {
+				zero = true
+			}
+			number = d
+			continue
+		}
+		unit := chineseUnits[r]
+		if unit == 10000 {
+			section += number
+			total += section * 10000
+			section, number = 0, 0
+			lastUnit = unit
+			zero = false
+			continue
+		}
+		if number == 0 && unit == 10 {
+			// "十六" 中省略的"一"
+			number = 1
+		}
+		section += number * unit
+		number = 0
+		lastUnit = unit
+		zero = false
+	}
+
+	// 口语中省略末位数位，如 "三千五" 为3500、"一百五" 为150
+	if number > 0 && !zero && lastUnit >= 100 {
+		number *= lastUnit / 10
+	}
+	return total + section + number, true
+}
@@ -0,0 +1,62 @@
+package normalize
+
+import "testing"
+
+func TestNumerals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"把空调调到二十六度", "把空调调到26度"},
+		{"亮度调到百分之三十", "亮度调到30%"},
+		{"零下五度", "-5度"},
+		{"明天早上七点叫我", "明天早上7点叫我"},
+		{"七点五十分提醒我", "7点50分提醒我"},
+		{"一百零五号房间", "105号房间"},
+		{"三千五", "3500"},
+		{"十度", "10度"},
+		{"十分钟后关灯", "10分钟后关灯"},
+		{"三十分钟后关灯", "30分钟后关灯"},
+		{"两个小时后", "2个小时后"},
+		{"再亮一点", "再亮一点"},
+		{"看一下客厅", "看一下客厅"},
+
+		// 只有数位或是常用词时不转换
+		{"千万别关客厅灯", "千万别关客厅灯"},
+		{"万一下雨就关窗", "万一下雨就关窗"},
+		{"客厅十分暗", "客厅十分暗"},
+		{"十分感谢", "十分感谢"},
+		{"百叶窗打开", "百叶窗打开"},
+		{"千万不要开门", "千万不要开门"},
+	}
+
+	for _, tt := range tests {
+		if got := Numerals(tt.input); got != tt.want {
+			t.Errorf("Numerals(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseChinese(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"二十六", "26", true},
+		{"十六", "16", true},
+		{"一百五", "150", true},
+		{"一二三", "123", true},
+		{"二十六点五", "26.5", true},
+		{"两万", "20000", true},
+		{"26", "26", true},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseChinese(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseChinese(%q) = %q, %v, want %q, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
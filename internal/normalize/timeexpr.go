@@ -0,0 +1,103 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationRe 相对时长，如 "30分钟后"、"半小时后"、"1个半小时以后"
+var durationRe = regexp.MustCompile(`^(\d+(?:\.\d+)?|半)?\s*个?\s*(半)?\s*(小时|钟头|分钟|分|秒钟|秒|天)\s*(?:后|以后|之后)$`)
+
+// clockRe 某天某时刻，如 "明天早上7点"、"今晚8点半"、"下午3点20分"、"23:30"
+var clockRe = regexp.MustCompile(`^(今天|今晚|今早|明天|明早|明晚|后天)?\s*(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上)?\s*(\d{1,2})\s*(?:点|时|:|：)\s*(?:(\d{1,2})\s*分?|(半)|整)?$`)
+
+// ParseTime 解析时间表达式
+// 支持RFC3339、相对时长（"半小时后"）和某天某时刻（"明天早上7点"），中文数字会先转换。
+// 没有指定日期且时刻已过时取第二天
+func ParseTime(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+
+	s = Numerals(s)
+
+	if m := durationRe.FindStringSubmatch(s); m != nil {
+		n := 0.0
+		switch m[1] {
+		case "":
+			n = 1
+			if m[2] == "半" {
+				n = 0
+			}
+		case "半":
+			n = 0.5
+		default:
+			n, _ = strconv.ParseFloat(m[1], 64)
+		}
+		if m[2] == "半" {
+			n += 0.5
+		}
+
+		var unit time.Duration
+		switch m[3] {
+		case "小时", "钟头":
+			unit = time.Hour
+		case "分钟", "分":
+			unit = time.Minute
+		case "秒钟", "秒":
+			unit = time.Second
+		case "天":
+			unit = 24 * time.Hour
+		}
+		return now.Add(time.Duration(n * float64(unit))).Truncate(time.Second), true
+	}
+
+	if m := clockRe.FindStringSubmatch(s); m != nil {
+		day, period := m[1], m[2]
+		hour, _ := strconv.Atoi(m[3])
+		minute := 0
+		if m[4] != "" {
+			minute, _ = strconv.Atoi(m[4])
+		} else if m[5] == "半" {
+			minute = 30
+		}
+
+		switch period {
+		case "下午", "傍晚", "晚上":
+			if hour < 12 {
+				hour += 12
+			}
+		case "中午":
+			if hour < 6 {
+				hour += 12
+			}
+		}
+		if day == "今晚" || day == "明晚" {
+			if hour < 12 {
+				hour += 12
+			}
+		}
+		if hour > 23 || minute > 59 {
+			return time.Time{}, false
+		}
+
+		offset := 0
+		switch day {
+		case "明天", "明早", "明晚":
+			offset = 1
+		case "后天":
+			offset = 2
+		}
+
+		t := time.Date(now.Year(), now.Month(), now.Day()+offset, hour, minute, 0, 0, now.Location())
+		if day == "" && !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, true
+	}
+
+	return time.Time{}, false
+}
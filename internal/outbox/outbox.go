@@ -0,0 +1,245 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// Entry 排队等待发送的请求
+type Entry struct {
+	// Request 原始请求
+	Request *model.KafkaRequest `json:"request"`
+
+	// Policy 处理器的分发策略（topic、分区键）
+	Policy model.Dispatch `json:"policy"`
+
+	// QueuedAt 入队时间
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// Outbox 磁盘上的请求队列
+// 请求以JSON行追加写入段文件（<序号>.log），重放进度记录在同名的 .pos 文件中；
+// 重放时先封存当前段，新的请求写入下一个段，整段发送完成后删除
+type Outbox struct {
+	dir         string
+	segmentSize int64
+
+	// maxAge 请求的最长排队时间，超过后重放时丢弃（0表示不过期）
+	maxAge time.Duration
+
+	// now 当前时间（测试时可替换）
+	now func() time.Time
+
+	mu        sync.Mutex
+	writer    *os.File
+	writeSeg  uint64
+	writeSize int64
+}
+
+// Open 打开（必要时创建）队列目录
+// 排队超过 maxAge 的请求在重放时丢弃，避免"开门"这类指令在故障恢复几小时后才执行
+func Open(dir string, segmentSize int64, maxAge time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建队列目录失败: %w", err)
+	}
+
+	o := &Outbox{dir: dir, segmentSize: segmentSize, maxAge: maxAge, now: time.Now}
+	segments, err := o.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		// 已有的段都视为封存，新请求写入新的段
+		o.writeSeg = segments[len(segments)-1]
+	}
+	return o, nil
+}
+
+// Append 追加请求并落盘
+func (o *Outbox) Append(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("序列化排队请求失败: %w", err)
+	}
+	data = append(data, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.writer == nil || (o.segmentSize > 0 && o.writeSize+int64(len(data)) > o.segmentSize) {
+		if err := o.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := o.writer.Write(data); err != nil {
+		return fmt.Errorf("写入队列失败: %w", err)
+	}
+	if err := o.writer.Sync(); err != nil {
+		return fmt.Errorf("写入队列失败: %w", err)
+	}
+	o.writeSize += int64(len(data))
+	return nil
+}
+
+// Empty 队列中是否没有待发送的请求
+func (o *Outbox) Empty() bool {
+	segments, err := o.segments()
+	return err == nil && len(segments) == 0
+}
+
+// Replay 按入队顺序发送队列中的请求，已过期的请求直接丢弃
+// send 返回错误时停止并保留进度，下次从失败的请求继续；返回本次发送成功的数量
+func (o *Outbox) Replay(send func(*Entry) error) (int, error) {
+	o.mu.Lock()
+	o.seal()
+	o.mu.Unlock()
+
+	segments, err := o.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, seg := range segments {
+		o.mu.Lock()
+		writing := o.writer != nil && seg == o.writeSeg
+		o.mu.Unlock()
+		if writing {
+			// 重放期间新写入的段留到下次
+			break
+		}
+
+		n, err := o.replaySegment(seg, send)
+		sent += n
+		if err != nil {
+			return sent, err
+		}
+		os.Remove(o.path(seg, ".log"))
+		os.Remove(o.path(seg, ".pos"))
+	}
+	return sent, nil
+}
+
+// replaySegment 发送一个段中尚未发送的请求
+func (o *Outbox) replaySegment(seg uint64, send func(*Entry) error) (int, error) {
+	f, err := os.Open(o.path(seg, ".log"))
+	if err != nil {
+		return 0, fmt.Errorf("打开队列段失败: %w", err)
+	}
+	defer f.Close()
+
+	done := o.readPos(seg)
+	line, sent := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line++
+		if line <= done {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Request == nil {
+			// 写入中途崩溃可能留下不完整的行
+			fmt.Printf("跳过无法解析的排队请求 (%s 第%d行): %v\n", o.path(seg, ".log"), line, err)
+		} else if age := o.now().Sub(e.QueuedAt); o.maxAge > 0 && age > o.maxAge {
+			fmt.Printf("[%s] 排队请求已过期（排队%v，处理器: %s），不再发送\n", e.Request.TraceID, age.Round(time.Second), e.Request.ProcessorID)
+		} else if err := send(&e); err != nil {
+			return sent, err
+		} else {
+			sent++
+		}
+
+		if err := o.writePos(seg, line); err != nil {
+			return sent, err
+		}
+	}
+	return sent, scanner.Err()
+}
+
+// rotate 开始新的写入段（调用方需持有锁）
+func (o *Outbox) rotate() error {
+	o.seal()
+	o.writeSeg++
+	f, err := os.OpenFile(o.path(o.writeSeg, ".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("创建队列段失败: %w", err)
+	}
+	o.writer = f
+	o.writeSize = 0
+	return nil
+}
+
+// seal 封存当前写入段（调用方需持有锁）
+func (o *Outbox) seal() {
+	if o.writer != nil {
+		o.writer.Close()
+		o.writer = nil
+	}
+}
+
+// segments 按序号列出队列中的段
+func (o *Outbox) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取队列目录失败: %w", err)
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		seg, err := strconv.ParseUint(strings.TrimSuffix(name, ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// readPos 读取段的重放进度（已处理的行数）
+func (o *Outbox) readPos(seg uint64) int {
+	data, err := os.ReadFile(o.path(seg, ".pos"))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
+
+// writePos 记录段的重放进度
+func (o *Outbox) writePos(seg uint64, line int) error {
+	tmp := o.path(seg, ".pos.tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(line)), 0o644); err != nil {
+		return fmt.Errorf("记录队列进度失败: %w", err)
+	}
+	return os.Rename(tmp, o.path(seg, ".pos"))
+}
+
+// path 段文件路径
+func (o *Outbox) path(seg uint64, ext string) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", seg, ext))
+}
+
+// Close 关闭当前写入段
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seal()
+	return nil
+}
@@ -0,0 +1,220 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// entry 测试用的排队请求
+func entry(id string, queuedAt time.Time) *Entry {
+	return &Entry{
+		Request:  &model.KafkaRequest{TraceID: id, ProcessorID: "light"},
+		QueuedAt: queuedAt,
+	}
+}
+
+// appendAll 按顺序追加请求
+func appendAll(t *testing.T, o *Outbox, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := o.Append(entry(id, o.now())); err != nil {
+			t.Fatalf("Append(%s) 失败: %v", id, err)
+		}
+	}
+}
+
+// collect 重放并返回发送的TraceID；failAt 非空时发送该请求返回错误
+func collect(t *testing.T, o *Outbox, failAt string) ([]string, error) {
+	t.Helper()
+	var got []string
+	_, err := o.Replay(func(e *Entry) error {
+		if e.Request.TraceID == failAt {
+			return errors.New("kafka不可用")
+		}
+		got = append(got, e.Request.TraceID)
+		return nil
+	})
+	return got, err
+}
+
+// logFiles 队列目录中的段文件数
+func logFiles(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestReplayOrderAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	// 每个段只能容纳一两条请求，强制轮转
+	o, err := Open(dir, 200, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	ids := []string{"t1", "t2", "t3", "t4", "t5", "t6"}
+	appendAll(t, o, ids...)
+	if n := logFiles(t, dir); n < 3 {
+		t.Fatalf("段文件数 = %d，期望按 segment_size 轮转出至少3个段", n)
+	}
+
+	got, err := collect(t, o, "")
+	if err != nil {
+		t.Fatalf("Replay 失败: %v", err)
+	}
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("重放顺序 = %v，期望 %v", got, ids)
+	}
+	if !o.Empty() || logFiles(t, dir) != 0 {
+		t.Errorf("重放完成后队列应为空，剩余段文件 %d 个", logFiles(t, dir))
+	}
+}
+
+func TestReplayResumesFromPos(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, o, "t1", "t2", "t3", "t4")
+
+	got, err := collect(t, o, "t3")
+	if err == nil {
+		t.Fatal("发送失败时 Replay 应返回错误")
+	}
+	if want := []string{"t1", "t2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("首次重放 = %v，期望 %v", got, want)
+	}
+	o.Close()
+
+	// 重启后从 .pos 记录的进度继续，已发送的请求不再重复
+	o, err = Open(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	appendAll(t, o, "t5")
+
+	got, err = collect(t, o, "")
+	if err != nil {
+		t.Fatalf("Replay 失败: %v", err)
+	}
+	if want := []string{"t3", "t4", "t5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("重启后重放 = %v，期望 %v", got, want)
+	}
+}
+
+func TestReplaySkipsCorruptLine(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	appendAll(t, o, "t1")
+
+	// 模拟写入中途崩溃留下的半行
+	f, err := os.OpenFile(o.path(o.writeSeg, ".log"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(f, "{\"request\":{\"trace_id\"\n")
+	f.Close()
+	appendAll(t, o, "t2")
+
+	got, err := collect(t, o, "")
+	if err != nil {
+		t.Fatalf("Replay 失败: %v", err)
+	}
+	if want := []string{"t1", "t2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("重放 = %v，期望 %v", got, want)
+	}
+}
+
+func TestReplayDropsExpired(t *testing.T) {
+	base := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		want   []string
+	}{
+		{"超过max_age的请求丢弃", 10 * time.Minute, []string{"fresh"}},
+		{"max_age为0不过期", 0, []string{"stale", "fresh"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := Open(t.TempDir(), 0, tt.maxAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+			o.now = func() time.Time { return base.Add(time.Hour) }
+
+			if err := o.Append(entry("stale", base)); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Append(entry("fresh", base.Add(55*time.Minute))); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := collect(t, o, "")
+			if err != nil {
+				t.Fatalf("Replay 失败: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("重放 = %v，期望 %v", got, tt.want)
+			}
+			if !o.Empty() {
+				t.Error("过期请求丢弃后队列应为空")
+			}
+		})
+	}
+}
+
+func TestAppendDuringReplay(t *testing.T) {
+	o, err := Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	appendAll(t, o, "t1", "t2")
+
+	// 重放期间写入的请求进入新的段，留到下次重放
+	var got []string
+	_, err = o.Replay(func(e *Entry) error {
+		got = append(got, e.Request.TraceID)
+		if e.Request.TraceID == "t1" {
+			appendAll(t, o, "t3")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay 失败: %v", err)
+	}
+	if want := []string{"t1", "t2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("首次重放 = %v，期望 %v", got, want)
+	}
+	if o.Empty() {
+		t.Fatal("重放期间写入的请求不应丢失")
+	}
+
+	got, err = collect(t, o, "")
+	if err != nil {
+		t.Fatalf("Replay 失败: %v", err)
+	}
+	if want := []string{"t3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("再次重放 = %v，期望 %v", got, want)
+	}
+}
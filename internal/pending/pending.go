@@ -0,0 +1,133 @@
+package pending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// ErrTimeout 在超时时间内没有收到最终响应
+var ErrTimeout = errors.New("等待响应超时")
+
+// bufferSize 每个等待方的中间消息缓冲，中间消息（进度、部分结果）可能连续到达
+const bufferSize = 32
+
+// ProgressFunc 收到中间消息（进度、部分结果）时的回调
+type ProgressFunc func(resp *model.KafkaResponse)
+
+// Waiter 一个等待中的请求
+// 最终响应单独保存，不会因为中间消息过多而丢失
+type Waiter struct {
+	progress chan *model.KafkaResponse
+	final    chan *model.KafkaResponse
+}
+
+// Registry 等待中的请求，后端的响应按 trace_id 交给对应的等待方
+// Kafka、MQTT 等异步后端共用，请求发送前登记，响应到达后投递
+type Registry struct {
+	mu      sync.RWMutex
+	waiters map[string]*Waiter
+}
+
+// NewRegistry 创建等待登记表
+func NewRegistry() *Registry {
+	return &Registry{waiters: make(map[string]*Waiter)}
+}
+
+// Register 登记等待响应的TraceID，需在发送请求之前调用，避免后端响应过快时在登记之前到达
+func (r *Registry) Register(traceID string) *Waiter {
+	w := &Waiter{
+		progress: make(chan *model.KafkaResponse, bufferSize),
+		final:    make(chan *model.KafkaResponse, 1),
+	}
+
+	r.mu.Lock()
+	r.waiters[traceID] = w
+	r.mu.Unlock()
+
+	return w
+}
+
+// Unregister 取消登记
+func (r *Registry) Unregister(traceID string) {
+	r.mu.Lock()
+	delete(r.waiters, traceID)
+	r.mu.Unlock()
+}
+
+// Deliver 把响应交给等待方，没有等待方（可能已超时）时返回false
+// 不阻塞：中间消息缓冲已满时丢弃最早的中间消息；最终响应只保留第一条，重复的最终响应丢弃
+func (r *Registry) Deliver(resp *model.KafkaResponse) bool {
+	r.mu.RLock()
+	w, ok := r.waiters[resp.TraceID]
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	if resp.IsFinal() {
+		select {
+		case w.final <- resp:
+		default:
+			fmt.Printf("[%s] 收到重复的最终响应，已忽略\n", resp.TraceID)
+		}
+		return true
+	}
+
+	for {
+		select {
+		case w.progress <- resp:
+			return true
+		default:
+		}
+		select {
+		case old := <-w.progress:
+			fmt.Printf("[%s] 中间消息过多，丢弃较早的消息 (status: %s, sequence: %d)\n", old.TraceID, old.Status, old.Sequence)
+		default:
+		}
+	}
+}
+
+// Wait 等待已登记的最终响应，中间消息交给 progress 处理
+// 每收到一条中间消息重新计算超时，长时间运行但持续报告进度的操作不会超时；ctx 结束时立即返回
+func Wait(ctx context.Context, w *Waiter, timeout time.Duration, progress ProgressFunc) (*model.KafkaResponse, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	lastSeq := 0
+	report := func(resp *model.KafkaResponse) {
+		if resp.Sequence > 0 && resp.Sequence <= lastSeq {
+			// 重复或乱序的中间消息
+			return
+		}
+		lastSeq = resp.Sequence
+		if progress != nil {
+			progress(resp)
+		}
+	}
+
+	for {
+		select {
+		case resp := <-w.final:
+			// 先处理已收到的中间消息，保证顺序
+			for len(w.progress) > 0 {
+				report(<-w.progress)
+			}
+			return resp, nil
+		case resp := <-w.progress:
+			report(resp)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(timeout)
+		case <-timer.C:
+			return nil, fmt.Errorf("%w（%v）", ErrTimeout, timeout)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
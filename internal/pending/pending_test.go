@@ -0,0 +1,85 @@
+package pending
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+func TestDeliverKeepsFinalWhenProgressFull(t *testing.T) {
+	r := NewRegistry()
+	w := r.Register("t1")
+
+	for i := 1; i <= bufferSize*2; i++ {
+		r.Deliver(&model.KafkaResponse{TraceID: "t1", Status: model.ResponseProgress, Sequence: i})
+	}
+	if !r.Deliver(&model.KafkaResponse{TraceID: "t1", Success: true, Result: "done"}) {
+		t.Fatal("最终响应应投递给等待方")
+	}
+	// 重复的最终响应不覆盖第一条
+	r.Deliver(&model.KafkaResponse{TraceID: "t1", Success: false})
+
+	var seqs []int
+	resp, err := Wait(context.Background(), w, time.Second, func(p *model.KafkaResponse) {
+		seqs = append(seqs, p.Sequence)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.Result != "done" {
+		t.Errorf("最终响应 = %+v", resp)
+	}
+	// 缓冲已满时丢弃最早的中间消息，保留最新的
+	if len(seqs) != bufferSize || seqs[0] != bufferSize+1 || seqs[len(seqs)-1] != bufferSize*2 {
+		t.Errorf("中间消息序号 = %v", seqs)
+	}
+}
+
+func TestDeliverWithoutWaiter(t *testing.T) {
+	r := NewRegistry()
+	w := r.Register("t1")
+	r.Unregister("t1")
+
+	if r.Deliver(&model.KafkaResponse{TraceID: "t1"}) {
+		t.Error("取消登记后不应投递")
+	}
+	if len(w.final) != 0 {
+		t.Error("取消登记后收到了响应")
+	}
+}
+
+func TestWaitSkipsDuplicateProgress(t *testing.T) {
+	r := NewRegistry()
+	w := r.Register("t1")
+
+	for _, seq := range []int{1, 2, 2, 1, 3} {
+		r.Deliver(&model.KafkaResponse{TraceID: "t1", Status: model.ResponseProgress, Sequence: seq})
+	}
+	r.Deliver(&model.KafkaResponse{TraceID: "t1", Success: true})
+
+	var seqs []int
+	if _, err := Wait(context.Background(), w, time.Second, func(p *model.KafkaResponse) {
+		seqs = append(seqs, p.Sequence)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != 3 || seqs[0] != 1 || seqs[1] != 2 || seqs[2] != 3 {
+		t.Errorf("中间消息序号 = %v，期望 [1 2 3]", seqs)
+	}
+}
+
+func TestWaitTimeoutAndCancel(t *testing.T) {
+	r := NewRegistry()
+
+	if _, err := Wait(context.Background(), r.Register("t1"), 10*time.Millisecond, nil); err == nil {
+		t.Error("没有响应时应超时")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Wait(ctx, r.Register("t2"), time.Second, nil); err != context.Canceled {
+		t.Errorf("err = %v，期望 context.Canceled", err)
+	}
+}
@@ -0,0 +1,136 @@
+package promptctx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// 内置提供者名称
+const (
+	ProviderTime        = "time"
+	ProviderLocale      = "locale"
+	ProviderDeviceState = "device_state"
+)
+
+// Request 收集上下文时的指令信息
+type Request struct {
+	// Processor 已匹配的处理器
+	Processor *model.Processor
+
+	// Message 用户消息
+	Message *model.UnifiedMessage
+
+	// Now 当前时间
+	Now time.Time
+
+	// Stable 提示词需要保持稳定（录制/回放LLM请求时），不输出随当前时间变化的内容
+	Stable bool
+}
+
+// Provider 提示词上下文提供者
+// 输出会渲染到参数提取的提示词中，帮助LLM把"再亮一点"、"调高两度"等相对说法换算为绝对的参数值
+type Provider interface {
+	// Name 提供者名称（对应 prompt_context.providers 配置）
+	Name() string
+
+	// Lines 返回与本次指令相关的上下文，每行一条；没有可提供的信息时返回nil
+	Lines(req Request) []string
+}
+
+// Collect 依次调用提供者并合并输出
+func Collect(providers []Provider, req Request) []string {
+	var lines []string
+	for _, p := range providers {
+		lines = append(lines, p.Lines(req)...)
+	}
+	return lines
+}
+
+// TimeProvider 提供当前时间、星期、时段和季节
+// 使用 Request.Now 的时区（prompt_context.timezone）
+type TimeProvider struct{}
+
+// Name 提供者名称
+func (p *TimeProvider) Name() string {
+	return ProviderTime
+}
+
+// Lines 输出当前时间信息，提示词需要保持稳定时不输出
+func (p *TimeProvider) Lines(req Request) []string {
+	if req.Stable {
+		return nil
+	}
+	now := req.Now
+	return []string{
+		fmt.Sprintf("当前时间：%s（%s，%s）", now.Format("2006-01-02 15:04"), weekdays[now.Weekday()], dayPeriod(now.Hour())),
+		fmt.Sprintf("当前季节：%s", season(now.Month())),
+	}
+}
+
+// weekdays 星期的中文名称
+var weekdays = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// dayPeriod 小时对应的时段
+func dayPeriod(hour int) string {
+	switch {
+	case hour < 5:
+		return "凌晨"
+	case hour < 8:
+		return "早晨"
+	case hour < 11:
+		return "上午"
+	case hour < 13:
+		return "中午"
+	case hour < 18:
+		return "下午"
+	case hour < 23:
+		return "晚上"
+	default:
+		return "深夜"
+	}
+}
+
+// season 月份对应的季节（北半球）
+func season(month time.Month) string {
+	switch month {
+	case time.March, time.April, time.May:
+		return "春季"
+	case time.June, time.July, time.August:
+		return "夏季"
+	case time.September, time.October, time.November:
+		return "秋季"
+	default:
+		return "冬季"
+	}
+}
+
+// LocaleProvider 提供用户的语言/地区
+// 优先使用消息中携带的 language_code（Telegram）或 locale（HTTP raw_data），否则使用默认值
+type LocaleProvider struct {
+	// Default 默认语言/地区
+	Default string
+}
+
+// Name 提供者名称
+func (p *LocaleProvider) Name() string {
+	return ProviderLocale
+}
+
+// Lines 输出用户语言
+func (p *LocaleProvider) Lines(req Request) []string {
+	locale := p.Default
+	if req.Message != nil {
+		for _, key := range []string{"language_code", "locale"} {
+			if v, ok := req.Message.RawData[key].(string); ok && v != "" {
+				locale = v
+				break
+			}
+		}
+	}
+	if locale == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf("用户语言/地区：%s", locale)}
+}
@@ -0,0 +1,163 @@
+package promptctx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// maxStateLines 每个处理器最多输出的设备状态条数
+const maxStateLines = 5
+
+// DeviceState 设备最近一次已知的状态
+type DeviceState struct {
+	// ProcessorID 处理器ID
+	ProcessorID string
+
+	// Target 设备标识（处理器中string类型参数的值，如房间），没有时为空
+	Target string
+
+	// Values 状态值（下发的参数合并后端返回的结果）
+	Values map[string]interface{}
+
+	// UpdatedAt 更新时间
+	UpdatedAt time.Time
+}
+
+// StateStore 记录后端确认执行成功后的设备状态，并作为上下文提供者输出
+type StateStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*DeviceState
+}
+
+// NewStateStore 创建设备状态存储，ttl 为状态的有效期（0表示不过期）
+func NewStateStore(ttl time.Duration) *StateStore {
+	return &StateStore{
+		ttl:     ttl,
+		entries: make(map[string]*DeviceState),
+	}
+}
+
+// Record 记录后端确认执行成功后的设备状态
+// result 为后端返回的结果，是对象时其中的标量字段会覆盖同名参数
+func (s *StateStore) Record(processor *model.Processor, params map[string]interface{}, result interface{}) {
+	var targets []string
+	isTarget := make(map[string]bool)
+	for _, p := range processor.Parameters {
+		if p.Type != "string" {
+			continue
+		}
+		if v, ok := params[p.Name]; ok {
+			targets = append(targets, fmt.Sprint(v))
+			isTarget[p.Name] = true
+		}
+	}
+	target := strings.Join(targets, "/")
+
+	key := processor.ID + "|" + target
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.entries[key]
+	if !ok {
+		state = &DeviceState{ProcessorID: processor.ID, Target: target, Values: make(map[string]interface{})}
+		s.entries[key] = state
+	}
+	for k, v := range params {
+		if !isTarget[k] {
+			state.Values[k] = v
+		}
+	}
+	if m, ok := result.(map[string]interface{}); ok {
+		for k, v := range m {
+			switch v.(type) {
+			case string, float64, bool, int, int64:
+				if !isTarget[k] {
+					state.Values[k] = v
+				}
+			}
+		}
+	}
+	state.UpdatedAt = time.Now()
+}
+
+// Name 提供者名称
+func (s *StateStore) Name() string {
+	return ProviderDeviceState
+}
+
+// Lines 输出该处理器最近的设备状态
+func (s *StateStore) Lines(req Request) []string {
+	if req.Processor == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	var states []DeviceState
+	for key, state := range s.entries {
+		if state.ProcessorID != req.Processor.ID {
+			continue
+		}
+		if s.ttl > 0 && req.Now.Sub(state.UpdatedAt) > s.ttl {
+			delete(s.entries, key)
+			continue
+		}
+		copied := *state
+		copied.Values = make(map[string]interface{}, len(state.Values))
+		for k, v := range state.Values {
+			copied.Values[k] = v
+		}
+		states = append(states, copied)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(states, func(i, j int) bool { return states[i].UpdatedAt.After(states[j].UpdatedAt) })
+	if len(states) > maxStateLines {
+		states = states[:maxStateLines]
+	}
+
+	var lines []string
+	for _, state := range states {
+		keys := make([]string, 0, len(state.Values))
+		for k := range state.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", k, state.Values[k]))
+		}
+
+		label := "设备最近状态"
+		if state.Target != "" {
+			label += "（" + state.Target + "）"
+		}
+		line := fmt.Sprintf("%s：%s", label, strings.Join(pairs, ", "))
+		if !req.Stable {
+			// 经过的时间每分钟都不同，提示词需要保持稳定时不输出
+			line += fmt.Sprintf("（%s前更新）", since(req.Now.Sub(state.UpdatedAt)))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// since 把时间间隔格式化为便于阅读的中文
+func since(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "不到1分钟"
+	case d < time.Hour:
+		return fmt.Sprintf("%d分钟", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d小时", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d天", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,180 @@
+package reply
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// Format 回复文本格式
+type Format string
+
+const (
+	// FormatPlain 纯文本
+	FormatPlain Format = "plain"
+
+	// FormatMarkdown Telegram MarkdownV2
+	FormatMarkdown Format = "markdown"
+)
+
+// FormatFor 渠道使用的回复格式：Telegram使用Markdown，其他渠道使用纯文本
+func FormatFor(channel model.MessageChannel) Format {
+	if channel == model.ChannelTelegram {
+		return FormatMarkdown
+	}
+	return FormatPlain
+}
+
+// Data 回复模板可用的数据
+type Data struct {
+	// Processor 处理器
+	Processor model.Processor
+
+	// Parameters 下发的参数
+	Parameters map[string]interface{}
+
+	// Result 后端返回的结果
+	Result interface{}
+
+	// Channel 消息来源渠道
+	Channel model.MessageChannel
+
+	// Markdown 是否使用Markdown格式
+	Markdown bool
+}
+
+// markdownSpecials Telegram MarkdownV2 中需要转义的字符
+const markdownSpecials = "\\_*[]()~`>#+-=|{}.!"
+
+// markdownEscaper 转义Telegram MarkdownV2中的特殊字符
+var markdownEscaper = func() *strings.Replacer {
+	var pairs []string
+	for _, ch := range markdownSpecials {
+		pairs = append(pairs, string(ch), "\\"+string(ch))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// codeEscaper 转义MarkdownV2代码中的特殊字符
+var codeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+// EscapeMarkdown 转义文本，使其在Telegram MarkdownV2中按原样显示
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// funcs 按格式生成模板函数：Markdown格式下输出对应标记，纯文本格式下原样输出
+func funcs(format Format) template.FuncMap {
+	markdown := format == FormatMarkdown
+	wrap := func(mark string) func(v interface{}) string {
+		return func(v interface{}) string {
+			s := fmt.Sprint(v)
+			if !markdown {
+				return s
+			}
+			if mark == "`" {
+				// 代码中只需转义 ` 和 \
+				return mark + codeEscaper.Replace(s) + mark
+			}
+			return mark + markdownEscaper.Replace(s) + mark
+		}
+	}
+	return template.FuncMap{
+		"bold":   wrap("*"),
+		"italic": wrap("_"),
+		"code":   wrap("`"),
+		"escape": func(v interface{}) string {
+			if !markdown {
+				return fmt.Sprint(v)
+			}
+			return markdownEscaper.Replace(fmt.Sprint(v))
+		},
+		"json": func(v interface{}) string {
+			data, _ := json.Marshal(v)
+			return string(data)
+		},
+		"join": strings.Join,
+	}
+}
+
+// Render 使用处理器的 reply 模板渲染后端结果
+// 处理器没有配置模板时返回 ok=false
+func Render(processor *model.Processor, data Data, format Format) (text string, ok bool, err error) {
+	if processor.Reply == "" {
+		return "", false, nil
+	}
+
+	data.Markdown = format == FormatMarkdown
+	tmpl, err := template.New(processor.ID + ".reply").Funcs(funcs(format)).Option("missingkey=zero").Parse(processor.Reply)
+	if err != nil {
+		return "", false, fmt.Errorf("解析处理器 %s 的回复模板失败: %w", processor.ID, err)
+	}
+	if data.Markdown {
+		// MarkdownV2 要求所有正文都转义：模板中的固定文本（如句末的"."、"!"）
+		// 和没有使用 bold/italic/code/escape 的输出（如 {{.Parameters.room}}）
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				escapeTree(t.Tree, t.Tree.Root)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", false, fmt.Errorf("渲染处理器 %s 的回复模板失败: %w", processor.ID, err)
+	}
+	return strings.TrimSpace(buf.String()), true, nil
+}
+
+// formatFuncs 自行处理Markdown转义的模板函数
+var formatFuncs = map[string]bool{"bold": true, "italic": true, "code": true, "escape": true}
+
+// escapeTree 转义模板语法树中的固定文本，并为未格式化的输出追加 escape
+func escapeTree(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeTree(tree, child)
+		}
+	case *parse.TextNode:
+		n.Text = []byte(EscapeMarkdown(string(n.Text)))
+	case *parse.ActionNode:
+		escapeAction(tree, n)
+	case *parse.IfNode:
+		escapeTree(tree, n.List)
+		escapeTree(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeTree(tree, n.List)
+		escapeTree(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeTree(tree, n.List)
+		escapeTree(tree, n.ElseList)
+	}
+}
+
+// escapeAction 为输出动作追加 escape，变量赋值和已格式化的输出不处理
+func escapeAction(tree *parse.Tree, n *parse.ActionNode) {
+	pipe := n.Pipe
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+		return
+	}
+	last := pipe.Cmds[len(pipe.Cmds)-1]
+	if len(last.Args) > 0 {
+		if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && formatFuncs[ident.Ident] {
+			return
+		}
+	}
+	pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      n.Pos,
+		Args:     []parse.Node{parse.NewIdentifier("escape").SetTree(tree).SetPos(n.Pos)},
+	})
+}
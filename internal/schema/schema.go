@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+)
+
+// files 请求/响应消息的JSON Schema，文件名为 <消息>-v<schema_version>.json
+//
+//go:embed schemas/*.json
+var files embed.FS
+
+// Get 按文件名获取JSON Schema
+func Get(name string) ([]byte, bool) {
+	if strings.Contains(name, "/") {
+		return nil, false
+	}
+	data, err := files.ReadFile(path.Join("schemas", name))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// List 所有已发布的JSON Schema文件名
+func List() []string {
+	entries, _ := files.ReadDir("schemas")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
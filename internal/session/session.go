@@ -0,0 +1,161 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// Session 多轮对话会话
+// 当指令缺少必填参数时创建，保存已匹配的处理器和已收集的参数，
+// 后续消息作为对追问的回答合并进参数，而不是重新进行意图识别。
+// 意图匹配有歧义时也会创建会话，此时 Choices 非空，后续消息作为用户的选择
+type Session struct {
+	// Key 会话键（渠道 + 会话/用户）
+	Key string
+
+	// TraceID 发起会话的请求TraceID
+	TraceID string
+
+	// ProcessorID 已匹配的处理器ID
+	ProcessorID string
+
+	// Content 发起会话的原始指令
+	Content string
+
+	// Parameters 已收集的参数
+	Parameters map[string]interface{}
+
+	// Missing 仍缺少的必填参数（按追问顺序）
+	Missing []string
+
+	// Choices 等待用户选择的候选处理器ID（按展示顺序）
+	Choices []string
+
+	// ChoiceConfidences 候选处理器的匹配置信度，用户选择后仍按它做敏感处理器检查
+	ChoiceConfidences map[string]float64
+
+	// CreatedAt 创建时间
+	CreatedAt time.Time
+
+	// ExpiresAt 过期时间
+	ExpiresAt time.Time
+}
+
+// Asking 返回当前正在追问的参数名
+func (s *Session) Asking() string {
+	if len(s.Missing) == 0 {
+		return ""
+	}
+	return s.Missing[0]
+}
+
+// Manager 会话管理器
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	timeout  time.Duration
+}
+
+// NewManager 创建会话管理器
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{
+		sessions: make(map[string]*Session),
+		timeout:  timeout,
+	}
+}
+
+// KeyOf 根据消息计算会话键
+// 同一渠道下按 ChatID + UserID 区分，HTTP等没有ChatID的渠道只按UserID区分
+func KeyOf(msg *model.UnifiedMessage) string {
+	return fmt.Sprintf("%s:%s:%s", msg.Channel, msg.ChatID, msg.UserID)
+}
+
+// Get 获取未过期的会话，不存在或已过期时返回nil
+func (m *Manager) Get(key string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(s.ExpiresAt) {
+		delete(m.sessions, key)
+		return nil
+	}
+	return s
+}
+
+// Save 保存会话并刷新过期时间
+func (m *Manager) Save(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	}
+	s.ExpiresAt = now.Add(m.timeout)
+	m.sessions[s.Key] = s
+
+	// 顺便清理过期会话
+	for key, other := range m.sessions {
+		if now.After(other.ExpiresAt) {
+			delete(m.sessions, key)
+		}
+	}
+}
+
+// Cancel 取消会话，返回会话是否存在
+func (m *Manager) Cancel(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[key]
+	if !ok {
+		return false
+	}
+	delete(m.sessions, key)
+	return time.Now().Before(s.ExpiresAt)
+}
+
+// MissingRequired 计算处理器仍缺少的必填参数（按参数定义顺序）
+// 配置了默认值的必填参数不需要追问
+func MissingRequired(processor *model.Processor, params map[string]interface{}) []string {
+	var missing []string
+	for _, p := range processor.Parameters {
+		if !p.Required || p.Default != nil {
+			continue
+		}
+		if v, ok := params[p.Name]; !ok || v == nil || v == "" {
+			missing = append(missing, p.Name)
+		}
+	}
+	return missing
+}
+
+// Question 生成针对缺失参数的追问
+func Question(processor *model.Processor, paramName string) string {
+	for _, p := range processor.Parameters {
+		if p.Name != paramName {
+			continue
+		}
+		desc := p.Description
+		if desc == "" {
+			desc = p.Name
+		}
+		question := fmt.Sprintf("【%s】还需要您补充：%s", processor.Name, desc)
+		if len(p.Values) > 0 {
+			question += fmt.Sprintf("（可选：%s）", strings.Join(p.Values, "、"))
+		}
+		if len(p.Range) == 2 {
+			question += fmt.Sprintf("（范围：%v-%v）", p.Range[0], p.Range[1])
+		}
+		return question + "\n回复“取消”可结束本次操作。"
+	}
+	return fmt.Sprintf("【%s】还需要您补充参数：%s\n回复“取消”可结束本次操作。", processor.Name, paramName)
+}
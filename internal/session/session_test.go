@@ -0,0 +1,134 @@
+package session
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+func TestKeyOf(t *testing.T) {
+	tests := []struct {
+		msg  model.UnifiedMessage
+		want string
+	}{
+		{model.UnifiedMessage{Channel: model.ChannelTelegram, ChatID: "100", UserID: "1"}, "telegram:100:1"},
+		{model.UnifiedMessage{Channel: model.ChannelTelegram, ChatID: "100", UserID: "2"}, "telegram:100:2"},
+		{model.UnifiedMessage{Channel: model.ChannelHTTP, UserID: "1"}, "http::1"},
+	}
+
+	for _, tt := range tests {
+		if got := KeyOf(&tt.msg); got != tt.want {
+			t.Errorf("KeyOf(%+v) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	m := NewManager(100 * time.Millisecond)
+	m.Save(&Session{Key: "a", Missing: []string{"room", "temperature"}})
+
+	s := m.Get("a")
+	if s == nil {
+		t.Fatal("保存后应能取到会话")
+	}
+	if s.Asking() != "room" {
+		t.Errorf("Asking() = %q，期望按顺序追问 room", s.Asking())
+	}
+	if m.Get("b") != nil {
+		t.Error("不存在的会话应返回nil")
+	}
+
+	// 保存时刷新过期时间
+	time.Sleep(60 * time.Millisecond)
+	m.Save(s)
+	time.Sleep(60 * time.Millisecond)
+	if m.Get("a") == nil {
+		t.Error("刷新后的会话不应过期")
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	if m.Get("a") != nil {
+		t.Error("超时的会话应返回nil")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := NewManager(time.Minute)
+	m.Save(&Session{Key: "a"})
+
+	if !m.Cancel("a") {
+		t.Error("取消存在的会话应返回true")
+	}
+	if m.Get("a") != nil {
+		t.Error("取消后会话应被删除")
+	}
+	if m.Cancel("a") {
+		t.Error("重复取消应返回false")
+	}
+}
+
+func TestMissingRequired(t *testing.T) {
+	processor := &model.Processor{
+		Parameters: []model.Parameter{
+			{Name: "room", Required: true},
+			{Name: "temperature", Required: true},
+			{Name: "mode", Required: true, Default: "cool"},
+			{Name: "fan"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   []string
+	}{
+		{"全部缺少，按定义顺序", map[string]interface{}{}, []string{"room", "temperature"}},
+		{"空值视为缺少", map[string]interface{}{"room": "", "temperature": nil}, []string{"room", "temperature"}},
+		{"有默认值的必填参数不追问", map[string]interface{}{"room": "卧室"}, []string{"temperature"}},
+		{"0是有效值", map[string]interface{}{"room": "卧室", "temperature": 0}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingRequired(processor, tt.params); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingRequired = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuestion(t *testing.T) {
+	processor := &model.Processor{
+		Name: "空调",
+		Parameters: []model.Parameter{
+			{Name: "room", Description: "房间"},
+			{Name: "mode", Values: []string{"cool", "heat"}},
+			{Name: "temperature", Description: "温度", Range: []float64{16, 30}},
+		},
+	}
+
+	tests := []struct {
+		param string
+		want  []string
+	}{
+		{"room", []string{"【空调】", "房间"}},
+		{"mode", []string{"mode", "可选：cool、heat"}},
+		{"temperature", []string{"温度", "范围：16-30"}},
+		{"unknown", []string{"补充参数：unknown"}},
+	}
+
+	for _, tt := range tests {
+		got := Question(processor, tt.param)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("Question(%s) = %q，应包含 %q", tt.param, got, want)
+			}
+		}
+		if !strings.Contains(got, "取消") {
+			t.Errorf("Question(%s) = %q，应提示如何取消", tt.param, got)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+// FieldError 单个参数的校验错误
+type FieldError struct {
+	// Param 参数名
+	Param string
+
+	// Value 原始值
+	Value interface{}
+
+	// Reason 错误原因
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("参数 %s %s", e.Param, e.Reason)
+	}
+	return fmt.Sprintf("参数 %s 的值 %v 无效: %s", e.Param, e.Value, e.Reason)
+}
+
+// Errors 参数校验错误列表
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate 按处理器的参数定义校验并转换参数
+// - 按 Parameter.Type 转换类型（如 "26" -> 26，"true" -> true）
+// - 未提供的参数使用 Default
+// - 检查必填、Range 范围和 Values 枚举值
+// 未在处理器中定义的参数原样保留。返回新的参数表，校验失败时返回 Errors
+func Validate(processor *model.Processor, params map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+
+	var errs Errors
+	for _, p := range processor.Parameters {
+		raw, ok := out[p.Name]
+		if !ok || isEmpty(raw) {
+			delete(out, p.Name)
+			if p.Default != nil {
+				raw = p.Default
+			} else {
+				if p.Required {
+					errs = append(errs, &FieldError{Param: p.Name, Reason: "为必填参数，但未提供"})
+				}
+				continue
+			}
+		}
+
+		value, err := coerce(p, raw)
+		if err != nil {
+			errs = append(errs, &FieldError{Param: p.Name, Value: raw, Reason: err.Error()})
+			continue
+		}
+		out[p.Name] = value
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// isEmpty 判断参数值是否视为未提供
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// coerce 按参数定义转换单个参数值
+func coerce(p model.Parameter, raw interface{}) (interface{}, error) {
+	switch p.Type {
+	case "int", "integer":
+		f, err := toFloat(raw)
+		if err != nil {
+			return nil, fmt.Errorf("应为整数")
+		}
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("应为整数")
+		}
+		if err := checkRange(p, f); err != nil {
+			return nil, err
+		}
+		return int(f), nil
+
+	case "float", "number":
+		f, err := toFloat(raw)
+		if err != nil {
+			return nil, fmt.Errorf("应为数字")
+		}
+		if err := checkRange(p, f); err != nil {
+			return nil, err
+		}
+		return f, nil
+
+	case "bool", "boolean":
+		b, err := toBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+
+	case "enum":
+		s := strings.TrimSpace(fmt.Sprint(raw))
+		for _, v := range p.Values {
+			if strings.EqualFold(s, v) {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("不在可选值 [%s] 中", strings.Join(p.Values, ", "))
+
+	case "string":
+		switch v := raw.(type) {
+		case string:
+			return strings.TrimSpace(v), nil
+		case float64, int, bool:
+			return fmt.Sprint(v), nil
+		default:
+			return nil, fmt.Errorf("应为字符串")
+		}
+
+	case "datetime":
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("应为时间")
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("应为RFC3339格式的时间")
+		}
+		return t.Format(time.RFC3339), nil
+
+	default:
+		// 未知类型不做转换
+		return raw, nil
+	}
+}
+
+// checkRange 检查数值是否在 Range 范围内
+func checkRange(p model.Parameter, f float64) error {
+	if len(p.Range) != 2 {
+		return nil
+	}
+	if f < p.Range[0] || f > p.Range[1] {
+		return fmt.Errorf("超出范围 [%v, %v]", p.Range[0], p.Range[1])
+	}
+	return nil
+}
+
+// toFloat 将任意值转换为float64
+func toFloat(raw interface{}) (float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("无法转换为数字: %T", raw)
+	}
+}
+
+// toBool 将任意值转换为bool
+func toBool(raw interface{}) (bool, error) {
+	switch v := raw.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case int:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "y", "on", "1", "是", "开", "对":
+			return true, nil
+		case "false", "no", "n", "off", "0", "否", "关", "不":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("应为布尔值")
+}
@@ -0,0 +1,163 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yoyo3287258/home-gateway/internal/model"
+)
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   model.Parameter
+		raw     interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		// 整数
+		{"整数", model.Parameter{Type: "int"}, float64(26), 26, false},
+		{"整数字符串", model.Parameter{Type: "int"}, " 26 ", 26, false},
+		{"integer别名", model.Parameter{Type: "integer"}, 3, 3, false},
+		{"整数不接受小数", model.Parameter{Type: "int"}, 26.5, nil, true},
+		{"整数不接受文字", model.Parameter{Type: "int"}, "二十六", nil, true},
+		{"整数在范围内", model.Parameter{Type: "int", Range: []float64{16, 30}}, "16", 16, false},
+		{"整数超出上限", model.Parameter{Type: "int", Range: []float64{16, 30}}, 31, nil, true},
+		{"整数低于下限", model.Parameter{Type: "int", Range: []float64{16, 30}}, 15, nil, true},
+
+		// 浮点数
+		{"浮点数字符串", model.Parameter{Type: "float"}, "26.5", 26.5, false},
+		{"number别名", model.Parameter{Type: "number"}, 1, float64(1), false},
+		{"浮点数超出范围", model.Parameter{Type: "float", Range: []float64{0, 1}}, 1.5, nil, true},
+		{"范围不完整时不检查", model.Parameter{Type: "float", Range: []float64{0}}, 100.0, 100.0, false},
+		{"浮点数不接受布尔", model.Parameter{Type: "float"}, true, nil, true},
+
+		// 布尔
+		{"布尔", model.Parameter{Type: "bool"}, true, true, false},
+		{"布尔字符串", model.Parameter{Type: "boolean"}, "Yes", true, false},
+		{"中文布尔", model.Parameter{Type: "bool"}, "关", false, false},
+		{"数字布尔", model.Parameter{Type: "bool"}, float64(1), true, false},
+		{"数字2不是布尔", model.Parameter{Type: "bool"}, 2, nil, true},
+		{"无法识别的布尔", model.Parameter{Type: "bool"}, "也许", nil, true},
+
+		// 枚举
+		{"枚举", model.Parameter{Type: "enum", Values: []string{"cool", "heat"}}, "cool", "cool", false},
+		{"枚举忽略大小写", model.Parameter{Type: "enum", Values: []string{"cool", "heat"}}, " HEAT ", "heat", false},
+		{"枚举数字", model.Parameter{Type: "enum", Values: []string{"1", "2"}}, float64(2), "2", false},
+		{"不在枚举中", model.Parameter{Type: "enum", Values: []string{"cool", "heat"}}, "auto", nil, true},
+
+		// 字符串
+		{"字符串去除空白", model.Parameter{Type: "string"}, " 客厅 ", "客厅", false},
+		{"数字转为字符串", model.Parameter{Type: "string"}, float64(101), "101", false},
+		{"字符串不接受对象", model.Parameter{Type: "string"}, map[string]interface{}{}, nil, true},
+
+		// 时间
+		{"时间", model.Parameter{Type: "datetime"}, "2024-01-01T08:00:00+08:00", "2024-01-01T08:00:00+08:00", false},
+		{"时间格式错误", model.Parameter{Type: "datetime"}, "明天早上八点", nil, true},
+		{"时间不接受数字", model.Parameter{Type: "datetime"}, float64(8), nil, true},
+
+		// 未知类型原样保留
+		{"未知类型", model.Parameter{Type: "color"}, "red", "red", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerce(tt.param, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("coerce(%v) 错误 = %v，期望出错 %v", tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("coerce(%v) = %#v，期望 %#v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	processor := &model.Processor{
+		ID: "ac_control",
+		Parameters: []model.Parameter{
+			{Name: "room", Type: "string", Required: true},
+			{Name: "temperature", Type: "int", Range: []float64{16, 30}},
+			{Name: "mode", Type: "enum", Values: []string{"cool", "heat"}, Default: "cool"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		params     map[string]interface{}
+		want       map[string]interface{}
+		wantErrors []string // 出错的参数名
+	}{
+		{
+			name:   "转换类型并补充默认值",
+			params: map[string]interface{}{"room": "卧室", "temperature": "26"},
+			want:   map[string]interface{}{"room": "卧室", "temperature": 26, "mode": "cool"},
+		},
+		{
+			name:   "未定义的参数原样保留",
+			params: map[string]interface{}{"room": "卧室", "fan": "high"},
+			want:   map[string]interface{}{"room": "卧室", "mode": "cool", "fan": "high"},
+		},
+		{
+			name:   "空字符串视为未提供，使用默认值",
+			params: map[string]interface{}{"room": "卧室", "mode": " "},
+			want:   map[string]interface{}{"room": "卧室", "mode": "cool"},
+		},
+		{
+			name:   "可选参数为空时删除",
+			params: map[string]interface{}{"room": "卧室", "temperature": nil},
+			want:   map[string]interface{}{"room": "卧室", "mode": "cool"},
+		},
+		{
+			name:       "缺少必填参数",
+			params:     map[string]interface{}{"temperature": 26},
+			wantErrors: []string{"room"},
+		},
+		{
+			name:       "报告所有出错的参数",
+			params:     map[string]interface{}{"room": "", "temperature": 35, "mode": "auto"},
+			wantErrors: []string{"room", "temperature", "mode"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate(processor, tt.params)
+			if len(tt.wantErrors) == 0 {
+				if err != nil {
+					t.Fatalf("Validate 失败: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Validate = %v，期望 %v", got, tt.want)
+				}
+				return
+			}
+
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate 错误 = %v，期望 Errors", err)
+			}
+			var params []string
+			for _, fe := range errs {
+				params = append(params, fe.Param)
+			}
+			if !reflect.DeepEqual(params, tt.wantErrors) {
+				t.Errorf("出错的参数 = %v，期望 %v", params, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestValidateDoesNotModifyInput(t *testing.T) {
+	processor := &model.Processor{Parameters: []model.Parameter{{Name: "level", Type: "int"}}}
+	params := map[string]interface{}{"level": "3"}
+
+	if _, err := Validate(processor, params); err != nil {
+		t.Fatal(err)
+	}
+	if params["level"] != "3" {
+		t.Errorf("Validate 修改了传入的参数表: %v", params)
+	}
+}